@@ -0,0 +1,101 @@
+package nyxsitemap
+
+import (
+    "fmt"
+    "path"
+    "sort"
+    "strings"
+    "time"
+)
+
+// WriteByType partitions URLs by the content type classify returns for
+// each, e.g. "pages", "images" or "videos", and writes every type as its
+// own set of sitemap files in a subdirectory of Dir named after it. A
+// master sitemap_index.xml in Dir then references each type's index, or
+// its single sitemap when it fits in one. Some search engines don't follow
+// indexes nested in an index; submit the type indexes to those directly.
+func (s *SitemapOptions) WriteByType(classify func(SitemapURL) string, baseSitemapURL string) error {
+    s.resetRun()
+    defer s.endRun(time.Now())
+
+    if err := checkBaseSitemapURL(baseSitemapURL); err != nil {
+        return err
+    }
+    groups := map[string][]SitemapURL{}
+    for _, u := range s.URLs {
+        contentType := classify(u)
+        if contentType == "" || contentType == "." || contentType == ".." ||
+            strings.ContainsAny(contentType, "/\\") || path.Clean(contentType) != contentType {
+            return fmt.Errorf("content type '%s' of '%s' must be a plain directory name", contentType, u.Loc)
+        }
+        groups[contentType] = append(groups[contentType], u)
+    }
+    types := make([]string, 0, len(groups))
+    for contentType := range groups {
+        types = append(types, contentType)
+    }
+    sort.Strings(types)
+
+    if err := s.ensureDir(); err != nil {
+        return err
+    }
+    // The master index references the stylesheet in Dir
+    if s.IncludeStylesheet {
+        if err := s.writeStylesheet(); err != nil {
+            return err
+        }
+    }
+    var entries []indexEntry
+    for _, contentType := range types {
+        name, urls, err := s.writeType(contentType, groups[contentType], baseSitemapURL)
+        if err != nil {
+            return fmt.Errorf("failed to write %s sitemaps: %v", contentType, err)
+        }
+        entry, err := s.newIndexEntry(baseSitemapURL, name, urls)
+        if err != nil {
+            return err
+        }
+        entries = append(entries, entry)
+    }
+
+    if err := s.writeIndexFile(entries, baseSitemapURL); err != nil {
+        return err
+    }
+    if err := s.validateXMLFile(path.Join(s.Dir, s.indexName()), true); err != nil {
+        return err
+    }
+    if err := s.mirrorFiles(); err != nil {
+        return err
+    }
+    return s.recordTopURL(baseSitemapURL)
+}
+
+// writeType writes urls of one content type into the subdirectory of Dir
+// named after it, with the same options, and records its files. It returns
+// the name of the file to reference from the master index, relative to
+// Dir, along with the URLs as written.
+func (s *SitemapOptions) writeType(contentType string, urls []SitemapURL, baseSitemapURL string) (string, []SitemapURL, error) {
+    sub := *s
+    sub.Dir = path.Join(s.Dir, contentType)
+    sub.URLs = urls
+    sub.resetRun()
+    sub.changes = nil
+    if err := sub.write(strings.TrimRight(baseSitemapURL, "/") + "/" + contentType + "/"); err != nil {
+        return "", nil, err
+    }
+
+    for _, file := range sub.files {
+        file.Name = path.Join(contentType, file.Name)
+        s.files = append(s.files, file)
+    }
+    s.stats.add(sub.stats)
+    s.warnings = append(s.warnings, sub.warnings...)
+    s.omitted += sub.omitted
+    s.changes = append(s.changes, sub.changes...)
+
+    name := sub.singleName()
+    if sub.index != nil {
+        name = sub.indexName()
+    }
+    return path.Join(contentType, name), sub.prepared, nil
+}
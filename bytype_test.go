@@ -0,0 +1,97 @@
+package nyxsitemap
+
+import (
+    "encoding/xml"
+    "strings"
+    "testing"
+)
+
+func TestWriteByType(t *testing.T) {
+    fs := NewMemFS()
+    sm := NewSitemapOptions("./test_sitemaps_by_type", "https://www.example.com")
+    sm.FS = fs
+    sm.MaxURLs = 2
+    for _, loc := range []string{"/", "/about", "/pricing", "/videos/intro", "/videos/demo", "/videos/tour"} {
+        sm.AddURL(SitemapURL{Loc: loc, LastMod: "2023-10-25"})
+    }
+
+    classify := func(u SitemapURL) string {
+        if strings.HasPrefix(u.Loc, "/videos/") {
+            return "videos"
+        }
+        return "pages"
+    }
+    // Every file, the master index included, is validated on write
+    if err := sm.WriteByType(classify, "https://www.example.com/sitemaps/"); err != nil {
+        t.Fatalf("Error writing sitemaps by type: %v", err)
+    }
+
+    data, err := fs.ReadFile("test_sitemaps_by_type/sitemap_index.xml")
+    if err != nil {
+        t.Fatalf("Error reading master index: %v", err)
+    }
+    var master SitemapIndex
+    if err := xml.Unmarshal(data, &master); err != nil {
+        t.Fatalf("Error parsing master index: %v", err)
+    }
+    expected := []string{
+        "https://www.example.com/sitemaps/pages/sitemap_index.xml",
+        "https://www.example.com/sitemaps/videos/sitemap_index.xml",
+    }
+    if len(master.Sitemaps) != len(expected) {
+        t.Fatalf("Expected %d entries in the master index, got %+v", len(expected), master.Sitemaps)
+    }
+    for i, loc := range expected {
+        if master.Sitemaps[i].Loc != loc {
+            t.Fatalf("Expected master entry %d to be %s, got %s", i, loc, master.Sitemaps[i].Loc)
+        }
+    }
+
+    data, err = fs.ReadFile("test_sitemaps_by_type/videos/sitemap_index.xml")
+    if err != nil {
+        t.Fatalf("Error reading videos index: %v", err)
+    }
+    if !strings.Contains(string(data), "<loc>https://www.example.com/sitemaps/videos/sitemap_2.xml</loc>") {
+        t.Fatalf("Expected the videos index to reference its own shards:\n%s", data)
+    }
+    if _, err := fs.ReadFile("test_sitemaps_by_type/pages/sitemap_2.xml"); err != nil {
+        t.Fatalf("Expected the pages shards under their subdirectory: %v", err)
+    }
+
+    count := 0
+    for _, file := range sm.Files() {
+        count += file.URLCount
+    }
+    if count != 6 {
+        t.Fatalf("Expected the files of both types to be recorded, got %d URLs in %+v", count, sm.Files())
+    }
+
+    if err := sm.WriteByType(func(SitemapURL) string { return "../escape" }, "https://www.example.com/sitemaps/"); err == nil {
+        t.Fatalf("Expected a content type escaping Dir to be rejected")
+    }
+}
+
+func TestWriteByTypeChanges(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_by_type_changes", "https://www.example.com")
+    sm.FS = NewMemFS()
+    sm.RecordChanges = true
+    for _, loc := range []string{"/", "/videos/intro"} {
+        sm.AddURL(SitemapURL{Loc: loc, LastMod: "2023-10-25"})
+    }
+
+    classify := func(u SitemapURL) string {
+        if strings.HasPrefix(u.Loc, "/videos/") {
+            return "videos"
+        }
+        return "pages"
+    }
+    if err := sm.WriteByType(classify, "https://www.example.com/sitemaps/"); err != nil {
+        t.Fatalf("Error writing sitemaps by type: %v", err)
+    }
+
+    // Every type's changes are kept, not only the last one's
+    changes := sm.Changes()
+    if len(changes) != 2 || changes[0].Loc != "/" || changes[1].Loc != "/videos/intro" {
+        t.Fatalf("Expected the loc changes of both types, got %+v", changes)
+    }
+}
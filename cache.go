@@ -0,0 +1,65 @@
+package nyxsitemap
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "strings"
+    "sync"
+)
+
+// validationCache maps a document's content hash and schema to the result
+// of validating it, for the lifetime of the process, up to
+// maxValidationCacheEntries results.
+var (
+    validationCacheMu sync.Mutex
+    validationCache   = map[string]error{}
+)
+
+// maxValidationCacheEntries bounds validationCache: once it is full, an
+// arbitrary entry is evicted for every new one.
+const maxValidationCacheEntries = 10000
+
+// onValidateXSD is a test hook called for every schema validation run, or
+// structural one without cgo.
+var onValidateXSD func()
+
+// validationCacheKey identifies a document validated against a schema.
+func validationCacheKey(data []byte, isIndex bool, schemaSources []string) string {
+    sum := sha256.Sum256(data)
+    schema := "sitemap"
+    if isIndex {
+        schema = "index"
+    } else if len(schemaSources) > 0 {
+        schema = strings.Join(schemaSources, "\n")
+    }
+    return hex.EncodeToString(sum[:]) + "\n" + schema
+}
+
+// cachedValidation returns the cached result for key, if any.
+func cachedValidation(key string) (bool, error) {
+    validationCacheMu.Lock()
+    defer validationCacheMu.Unlock()
+    err, ok := validationCache[key]
+    return ok, err
+}
+
+// storeValidation caches the validation result for key.
+func storeValidation(key string, err error) {
+    validationCacheMu.Lock()
+    defer validationCacheMu.Unlock()
+    if _, ok := validationCache[key]; !ok && len(validationCache) >= maxValidationCacheEntries {
+        for evicted := range validationCache {
+            delete(validationCache, evicted)
+            break
+        }
+    }
+    validationCache[key] = err
+}
+
+// ClearValidationCache forgets all validation results cached through
+// CacheValidation.
+func ClearValidationCache() {
+    validationCacheMu.Lock()
+    defer validationCacheMu.Unlock()
+    validationCache = map[string]error{}
+}
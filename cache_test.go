@@ -0,0 +1,98 @@
+package nyxsitemap
+
+import (
+    "os"
+    "path"
+    "strconv"
+    "sync/atomic"
+    "testing"
+)
+
+func TestValidationCache(t *testing.T) {
+    counter := countValidations(t)
+    dir := "./test_sitemaps_cache"
+    os.RemoveAll(dir)
+    defer os.RemoveAll(dir)
+    ClearValidationCache()
+    defer ClearValidationCache()
+
+    sm := NewSitemapOptions(dir, "https://www.example.com")
+    sm.CacheValidation = true
+    sm.AddURL(SitemapURL{Loc: "/", LastMod: "2023-10-25"})
+    if err := sm.Write("https://www.example.com/"); err != nil {
+        t.Fatalf("Error writing sitemaps: %v", err)
+    }
+
+    filePath := path.Join(dir, "sitemap.xml")
+    before := atomic.LoadInt64(counter)
+    if err := sm.validateXMLFile(filePath, false); err != nil {
+        t.Fatalf("Error validating sitemap: %v", err)
+    }
+    if calls := atomic.LoadInt64(counter) - before; calls != 0 {
+        t.Fatalf("Expected identical content to hit the cache, got %d validations", calls)
+    }
+
+    ClearValidationCache()
+    if err := sm.validateXMLFile(filePath, false); err != nil {
+        t.Fatalf("Error validating sitemap: %v", err)
+    }
+    if calls := atomic.LoadInt64(counter) - before; calls != 1 {
+        t.Fatalf("Expected a validation after clearing the cache, got %d", calls)
+    }
+}
+
+func TestSkipValidationFor(t *testing.T) {
+    counter := countValidations(t)
+    sm := NewSitemapOptions("./test_sitemaps_skip_validation", "https://www.example.com")
+    sm.FS = NewMemFS()
+    sm.MaxURLs = 1
+    sm.AddURL(SitemapURL{Loc: "/a", LastMod: "2023-10-25"})
+    sm.AddURL(SitemapURL{Loc: "/b", LastMod: "2023-10-25"})
+
+    var skipped []string
+    sm.SkipValidationFor = func(filename string) bool {
+        if filename == "sitemap_1.xml" {
+            skipped = append(skipped, filename)
+            return true
+        }
+        return false
+    }
+
+    before := atomic.LoadInt64(counter)
+    if err := sm.Write("https://www.example.com/"); err != nil {
+        t.Fatalf("Error writing sitemaps: %v", err)
+    }
+    // The index and sitemap_2.xml are still validated
+    if calls := atomic.LoadInt64(counter) - before; calls != 2 {
+        t.Fatalf("Expected 2 validations with sitemap_1.xml skipped, got %d", calls)
+    }
+    if len(skipped) != 1 {
+        t.Fatalf("Expected sitemap_1.xml to be skipped once, got %v", skipped)
+    }
+}
+
+// countValidations counts schema validation runs until the test ends.
+func countValidations(t *testing.T) *int64 {
+    var counter int64
+    onValidateXSD = func() { atomic.AddInt64(&counter, 1) }
+    t.Cleanup(func() { onValidateXSD = nil })
+    return &counter
+}
+
+func TestValidationCacheBound(t *testing.T) {
+    ClearValidationCache()
+    defer ClearValidationCache()
+
+    for i := 0; i < maxValidationCacheEntries+10; i++ {
+        storeValidation(strconv.Itoa(i), nil)
+    }
+    validationCacheMu.Lock()
+    size := len(validationCache)
+    validationCacheMu.Unlock()
+    if size != maxValidationCacheEntries {
+        t.Fatalf("Expected the cache to hold at most %d results, got %d", maxValidationCacheEntries, size)
+    }
+    if ok, _ := cachedValidation(strconv.Itoa(maxValidationCacheEntries + 9)); !ok {
+        t.Fatalf("Expected the latest result to be cached")
+    }
+}
@@ -0,0 +1,33 @@
+package nyxsitemap
+
+// URLChange records a value of a URL altered by the package, e.g. a loc
+// resolved against BaseURL or a missing lastmod filled in.
+type URLChange struct {
+    Loc      string // Loc of the URL when the change was made
+    Field    string // "loc", "lastmod" or "priority"
+    Original string
+    Final    string
+    Reason   string
+}
+
+// Changes returns the changes made to URLs by the last write, and by AddURL
+// to the URLs before it or since, in the order they were made. Each write
+// drops the changes of the previous one. Writes apply their changes to a
+// copy of URLs, so writing the same URLs again records them again.
+func (s *SitemapOptions) Changes() []URLChange {
+    return s.changes
+}
+
+// recordChange records a change of field, unless the value is unchanged.
+func (s *SitemapOptions) recordChange(loc, field, original, final, reason string) {
+    if !s.RecordChanges || original == final {
+        return
+    }
+    s.changes = append(s.changes, URLChange{
+        Loc:      loc,
+        Field:    field,
+        Original: original,
+        Final:    final,
+        Reason:   reason,
+    })
+}
@@ -0,0 +1,67 @@
+package nyxsitemap
+
+import (
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestChanges(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_changes", "https://www.example.com")
+    sm.FS = NewMemFS()
+    sm.RecordChanges = true
+    sm.clock = func() time.Time { return time.Date(2023, 10, 25, 12, 0, 0, 0, time.UTC) }
+    sm.Transforms = []func(SitemapURL) (SitemapURL, bool){
+        func(u SitemapURL) (SitemapURL, bool) {
+            u.Loc = strings.TrimSuffix(u.Loc, "/")
+            return u, true
+        },
+    }
+
+    sm.AddURL(SitemapURL{Loc: " /a ", LastMod: "2023-10-01"})
+    sm.AddURL(SitemapURL{Loc: "https://www.example.com/b/"})
+    sm.AddURL(SitemapURL{Loc: "https://www.example.com/c", LastMod: "2030-01-01"})
+    sm.RoundPriority = true
+    if err := sm.AddURLValidated(SitemapURL{Loc: "https://www.example.com/d", LastMod: "2023-10-01", Priority: "0.75"}); err != nil {
+        t.Fatalf("Error adding URL: %v", err)
+    }
+    if err := sm.Write(""); err != nil {
+        t.Fatalf("Error writing sitemap: %v", err)
+    }
+
+    expected := []URLChange{
+        {"https://www.example.com/b/", "lastmod", "", "2023-10-25", "missing lastmod set to today"},
+        {"https://www.example.com/c", "lastmod", "2030-01-01", "2023-10-25", "future lastmod capped to today"},
+        {"https://www.example.com/d", "priority", "0.75", "0.8", "priority rounded to one decimal place"},
+        {" /a ", "loc", " /a ", "https://www.example.com/a", "loc resolved against BaseURL"},
+        {"https://www.example.com/b/", "loc", "https://www.example.com/b/", "https://www.example.com/b", "rewritten by Transforms"},
+    }
+    changes := sm.Changes()
+    if len(changes) != len(expected) {
+        t.Fatalf("Expected %d changes, got %+v", len(expected), changes)
+    }
+    for i, change := range expected {
+        if changes[i] != change {
+            t.Fatalf("Expected change %d to be %+v, got %+v", i, change, changes[i])
+        }
+    }
+
+    // Writing again records the write's changes again, not AddURL's
+    if err := sm.Write(""); err != nil {
+        t.Fatalf("Error writing sitemap: %v", err)
+    }
+    changes = sm.Changes()
+    if len(changes) != 2 || changes[0] != expected[3] || changes[1] != expected[4] {
+        t.Fatalf("Expected the write's changes on rewrite, got %+v", changes)
+    }
+
+    // Changes made by AddURL are kept for the write that follows
+    sm.AddURL(SitemapURL{Loc: "https://www.example.com/e"})
+    if err := sm.Write(""); err != nil {
+        t.Fatalf("Error writing sitemap: %v", err)
+    }
+    changes = sm.Changes()
+    if len(changes) != 3 || changes[0].Loc != "https://www.example.com/e" {
+        t.Fatalf("Expected the change of the added URL first, got %+v", changes)
+    }
+}
@@ -0,0 +1,85 @@
+package nyxsitemap
+
+import (
+    "fmt"
+    "reflect"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// String renders every exported option with defaults resolved, one
+// "Name: value" per line, e.g. for inclusion in bug reports. URLs are
+// summarized by count and functions by whether they are set.
+func (s *SitemapOptions) String() string {
+    var b strings.Builder
+    v := reflect.ValueOf(s).Elem()
+    for i := 0; i < v.NumField(); i++ {
+        field := v.Type().Field(i)
+        if !field.IsExported() {
+            continue
+        }
+        value, ok := s.resolvedOption(field.Name)
+        if !ok {
+            value = describeOption(v.Field(i))
+        }
+        fmt.Fprintf(&b, "%s: %s\n", field.Name, value)
+    }
+    return b.String()
+}
+
+// resolvedOption renders the options whose zero value stands for a
+// computed default, and reports whether name is one of them.
+func (s *SitemapOptions) resolvedOption(name string) (string, bool) {
+    switch name {
+    case "IndexThreshold":
+        return strconv.Itoa(s.indexThreshold()), true
+    case "Concurrency":
+        return strconv.Itoa(s.concurrency()), true
+    case "UserAgent":
+        return s.userAgent(), true
+    case "HTTPClient":
+        if s.HTTPClient == nil {
+            return "http.DefaultClient", true
+        }
+        return "custom", true
+    case "HTTPLimiter":
+        if s.HTTPLimiter == nil {
+            return "unbounded", true
+        }
+        return "custom", true
+    }
+    return "", false
+}
+
+// describeOption renders a single option value for String.
+func describeOption(value reflect.Value) string {
+    switch option := value.Interface().(type) {
+    case []SitemapURL:
+        return fmt.Sprintf("%d URLs", len(option))
+    case FileSystem:
+        return fmt.Sprintf("%T", option)
+    case *time.Location:
+        if option == nil {
+            return "UTC"
+        }
+        return option.String()
+    }
+
+    switch value.Kind() {
+    case reflect.Func:
+        if value.IsNil() {
+            return "unset"
+        }
+        return "set"
+    case reflect.Slice:
+        if fn := value.Type().Elem(); fn.Kind() == reflect.Func {
+            return fmt.Sprintf("%d set", value.Len())
+        }
+    case reflect.Interface:
+        if value.IsNil() {
+            return "local disk"
+        }
+    }
+    return fmt.Sprintf("%v", value.Interface())
+}
@@ -0,0 +1,42 @@
+package nyxsitemap
+
+import (
+    "runtime"
+    "strconv"
+    "strings"
+    "testing"
+)
+
+func TestOptionsString(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_config", "https://www.example.com/")
+    sm.AddURL(SitemapURL{Loc: "/"})
+    sm.Canonical = true
+    sm.SkipValidationFor = func(string) bool { return false }
+
+    config := sm.String()
+    for _, line := range []string{
+        "Dir: ./test_sitemaps_config\n",
+        "BaseURL: https://www.example.com\n",
+        "MaxURLs: " + strconv.Itoa(maxURLsPerSitemap) + "\n",
+        "MaxFileSize: 52428800\n",
+        "URLs: 1 URLs\n",
+        "FS: local disk\n",
+        "IncludeStylesheet: true\n",
+        "Canonical: true\n",
+        "SkipValidationFor: set\n",
+        "PriorityDecay: unset\n",
+        "TimeZone: UTC\n",
+        "IndexThreshold: " + strconv.Itoa(maxURLsPerSitemap) + "\n",
+        "Concurrency: " + strconv.Itoa(runtime.GOMAXPROCS(0)) + "\n",
+        "UserAgent: nyxsitemap/devel\n",
+        "HTTPClient: http.DefaultClient\n",
+        "DirLayout: Flat\n",
+        "IndexOrder: Numeric\n",
+        "SplitMode: FixedCount\n",
+        "DuplicateHreflang: RejectDuplicateHreflang\n",
+    } {
+        if !strings.Contains(config, line) {
+            t.Fatalf("Expected %q in the rendered options, got:\n%s", line, config)
+        }
+    }
+}
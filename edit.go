@@ -0,0 +1,69 @@
+package nyxsitemap
+
+import (
+    "encoding/xml"
+    "fmt"
+    "path"
+)
+
+// Edit reads the sitemap file at filePath from FS, replaces its URLs with
+// the result of fn, and writes it back once the new content validates as
+// Write would validate it, atomically when FS implements Renamer. Gzipped
+// files are detected, and are written back gzipped.
+func (s *SitemapOptions) Edit(filePath string, fn func([]SitemapURL) []SitemapURL) error {
+    raw, urlSet, err := readURLSetFrom(s.fs(), filePath)
+    if err != nil {
+        return err
+    }
+
+    data, err := s.marshalURLSet(path.Base(filePath), fn(urlSet.URLs))
+    if err != nil {
+        return err
+    }
+    if !s.skipValidation(filePath) {
+        if err := s.validateBytes(data, false); err != nil {
+            return err
+        }
+    }
+    if isGzip(raw) {
+        if data, err = gzipBytes(data); err != nil {
+            return err
+        }
+    }
+
+    // Replace the file atomically through a temporary file in the same
+    // directory
+    renamer, ok := s.fs().(Renamer)
+    if !ok {
+        return s.fs().WriteFile(filePath, data, 0644)
+    }
+    tmp := filePath + ".tmp"
+    if err := s.fs().WriteFile(tmp, data, 0644); err != nil {
+        return err
+    }
+    return renamer.Rename(tmp, filePath)
+}
+
+// readURLSet reads and parses the sitemap file at filePath, decompressing it
+// when gzipped. The raw file content is returned alongside.
+func readURLSet(filePath string) ([]byte, URLSet, error) {
+    return readURLSetFrom(osFS{}, filePath)
+}
+
+// readURLSetFrom is readURLSet reading from fsys.
+func readURLSetFrom(fsys FileSystem, filePath string) ([]byte, URLSet, error) {
+    raw, err := fsys.ReadFile(filePath)
+    if err != nil {
+        return nil, URLSet{}, err
+    }
+    data, err := gunzipIfNeeded(raw)
+    if err != nil {
+        return nil, URLSet{}, fmt.Errorf("failed to decompress sitemap '%s': %v", filePath, err)
+    }
+
+    var urlSet URLSet
+    if err := xml.Unmarshal(data, &urlSet); err != nil {
+        return nil, URLSet{}, fmt.Errorf("XML unmarshalling failed for sitemap '%s': %v", filePath, err)
+    }
+    return raw, urlSet, nil
+}
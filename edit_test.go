@@ -0,0 +1,92 @@
+package nyxsitemap
+
+import (
+    "os"
+    "path"
+    "strings"
+    "testing"
+)
+
+func TestEdit(t *testing.T) {
+    dir := "./test_sitemaps_edit"
+    os.RemoveAll(dir)
+    defer os.RemoveAll(dir)
+
+    sm := NewSitemapOptions(dir, "https://www.example.com")
+    sm.AddURL(SitemapURL{Loc: "/a"})
+    sm.AddURL(SitemapURL{Loc: "/b"})
+    sm.AddURL(SitemapURL{Loc: "/c"})
+    if err := sm.Write("https://www.example.com/"); err != nil {
+        t.Fatalf("Error writing sitemaps: %v", err)
+    }
+
+    filePath := path.Join(dir, "sitemap.xml")
+    before, _ := os.ReadFile(filePath)
+
+    dropB := func(urls []SitemapURL) []SitemapURL {
+        var kept []SitemapURL
+        for _, u := range urls {
+            if u.Loc != "https://www.example.com/b" {
+                kept = append(kept, u)
+            }
+        }
+        return kept
+    }
+    if err := sm.Edit(filePath, dropB); err != nil {
+        t.Fatalf("Error editing sitemap: %v", err)
+    }
+
+    after, _ := os.ReadFile(filePath)
+    if len(after) >= len(before) {
+        t.Fatalf("Expected sitemap to shrink, got %d bytes from %d", len(after), len(before))
+    }
+    if strings.Contains(string(after), "https://www.example.com/b") {
+        t.Fatalf("Expected /b to be dropped")
+    }
+    if err := sm.validateXMLFile(filePath, false); err != nil {
+        t.Fatalf("Edited sitemap does not validate: %v", err)
+    }
+
+    // Gzipped sitemaps stay gzipped
+    gzipped, _ := gzipBytes(after)
+    gzPath := path.Join(dir, "sitemap.xml.gz")
+    os.WriteFile(gzPath, gzipped, 0644)
+    if err := sm.Edit(gzPath, dropB); err != nil {
+        t.Fatalf("Error editing gzipped sitemap: %v", err)
+    }
+    edited, _ := os.ReadFile(gzPath)
+    if !isGzip(edited) {
+        t.Fatalf("Expected edited sitemap to remain gzipped")
+    }
+}
+
+func TestEditMemFS(t *testing.T) {
+    fs := NewMemFS()
+    sm := NewSitemapOptions("./test_sitemaps_edit_memfs", "https://www.example.com")
+    sm.FS = fs
+    sm.AddURL(SitemapURL{Loc: "/a", LastMod: "2023-10-25"})
+    if err := sm.Write(""); err != nil {
+        t.Fatalf("Error writing sitemap: %v", err)
+    }
+
+    filePath := "test_sitemaps_edit_memfs/sitemap.xml"
+    invalidate := func(urls []SitemapURL) []SitemapURL {
+        return append(urls, SitemapURL{Loc: "https://www.example.com/b", Priority: "high"})
+    }
+    if err := sm.Edit(filePath, invalidate); err == nil {
+        t.Fatalf("Expected an invalid edit to be rejected")
+    }
+
+    // SkipValidationFor applies to edits as it does to writes
+    sm.SkipValidationFor = func(filename string) bool { return filename == "sitemap.xml" }
+    if err := sm.Edit(filePath, invalidate); err != nil {
+        t.Fatalf("Error editing sitemap in MemFS: %v", err)
+    }
+    data, err := fs.ReadFile(filePath)
+    if err != nil || !strings.Contains(string(data), "<priority>high</priority>") {
+        t.Fatalf("Expected the edit to be written to MemFS, got %s, %v", data, err)
+    }
+    if _, err := fs.Stat(filePath + ".tmp"); err == nil {
+        t.Fatalf("Expected the temporary file to be renamed into place")
+    }
+}
@@ -0,0 +1,348 @@
+package nyxsitemap
+
+import (
+    "encoding/xml"
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+    "unicode/utf8"
+)
+
+// Extension is a raw child element of <url> in a foreign namespace, e.g. a
+// vendor-specific element understood by a private crawler.
+type Extension struct {
+    XMLName  xml.Name    // Space holds the namespace URI
+    Attrs    []xml.Attr  `xml:",any,attr"`
+    Value    string      `xml:",chardata"`
+    Children []Extension `xml:",any"`
+}
+
+// extensionPrefixes are the conventional prefixes of the well-known
+// extension namespaces. Extensions in these namespaces are written with the
+// prefix, declared once on <urlset> when used, as the Google schemas and
+// some validators expect.
+var extensionPrefixes = map[string]string{
+    imageNamespace: "image",
+    videoNamespace: "video",
+    newsNamespace:  "news",
+    xhtmlNamespace: "xhtml",
+}
+
+// The namespaces of the Google image, video and news extensions.
+const (
+    imageNamespace = "http://www.google.com/schemas/sitemap-image/1.1"
+    videoNamespace = "http://www.google.com/schemas/sitemap-video/1.1"
+    newsNamespace  = "http://www.google.com/schemas/sitemap-news/0.9"
+)
+
+// prefixExtensions returns urls with the extensions in well-known
+// namespaces renamed to their prefixed form, along with the xmlns
+// declarations for the prefixes used. The given slice is left untouched.
+func prefixExtensions(urls []SitemapURL) ([]SitemapURL, map[string]string) {
+    declarations := map[string]string{}
+    var prefixed []SitemapURL
+    for i, u := range urls {
+        if len(u.Extensions) == 0 {
+            continue
+        }
+        if prefixed == nil {
+            prefixed = append([]SitemapURL(nil), urls...)
+        }
+        u.Extensions = prefixExtensionNames(u.Extensions, declarations)
+        prefixed[i] = u
+    }
+    if prefixed == nil {
+        return urls, declarations
+    }
+    return prefixed, declarations
+}
+
+// prefixExtensionNames returns a copy of exts with well-known namespaces
+// replaced by their prefix, recording the declarations needed.
+func prefixExtensionNames(exts []Extension, declarations map[string]string) []Extension {
+    renamed := make([]Extension, len(exts))
+    for i, ext := range exts {
+        if prefix, ok := extensionPrefixes[ext.XMLName.Space]; ok {
+            declarations["xmlns:"+prefix] = ext.XMLName.Space
+            ext.XMLName = xml.Name{Local: prefix + ":" + ext.XMLName.Local}
+        }
+        if len(ext.Children) > 0 {
+            ext.Children = prefixExtensionNames(ext.Children, declarations)
+            // Drop the indentation read back along with parsed children
+            if strings.TrimSpace(ext.Value) == "" {
+                ext.Value = ""
+            }
+        }
+        renamed[i] = ext
+    }
+    return renamed
+}
+
+// SitemapImage is an image on a page, emitted as an image:image extension
+// for Google Image indexing. Loc is required and resolved against BaseURL;
+// Write rejects images without one, and warns about very long titles and
+// captions.
+type SitemapImage struct {
+    Loc     string
+    Caption string
+    Title   string
+}
+
+// Alternate is a version of a page in another language or region, emitted
+// as an xhtml:link with rel="alternate". Href is resolved against BaseURL.
+type Alternate struct {
+    Hreflang string // e.g. "fr", "en-GB" or "x-default"
+    Href     string
+}
+
+// withFieldExtensions returns urls with the extensions derived from their
+// fields, Images, Alternates and AMPURL, appended to Extensions. The given
+// slice is left untouched.
+func withFieldExtensions(urls []SitemapURL) []SitemapURL {
+    return withAMPLinks(withAlternates(withImages(urls)))
+}
+
+// withAlternates returns urls with an xhtml:link extension added for every
+// alternate in Alternates. The given slice is left untouched.
+func withAlternates(urls []SitemapURL) []SitemapURL {
+    var linked []SitemapURL
+    for i, u := range urls {
+        if len(u.Alternates) == 0 {
+            continue
+        }
+        if linked == nil {
+            linked = append([]SitemapURL(nil), urls...)
+        }
+        u.Extensions = append([]Extension(nil), u.Extensions...)
+        for _, alternate := range u.Alternates {
+            u.Extensions = append(u.Extensions, Extension{
+                XMLName: xml.Name{Space: xhtmlNamespace, Local: "link"},
+                Attrs: []xml.Attr{
+                    {Name: xml.Name{Local: "rel"}, Value: "alternate"},
+                    {Name: xml.Name{Local: "hreflang"}, Value: alternate.Hreflang},
+                    {Name: xml.Name{Local: "href"}, Value: alternate.Href},
+                },
+            })
+        }
+        linked[i] = u
+    }
+    if linked == nil {
+        return urls
+    }
+    return linked
+}
+
+// withImages returns urls with an image:image extension added for every
+// image in Images. The given slice is left untouched.
+func withImages(urls []SitemapURL) []SitemapURL {
+    var imaged []SitemapURL
+    for i, u := range urls {
+        if len(u.Images) == 0 {
+            continue
+        }
+        if imaged == nil {
+            imaged = append([]SitemapURL(nil), urls...)
+        }
+        u.Extensions = append([]Extension(nil), u.Extensions...)
+        for _, image := range u.Images {
+            u.Extensions = append(u.Extensions, imageExtension(image))
+        }
+        imaged[i] = u
+    }
+    if imaged == nil {
+        return urls
+    }
+    return imaged
+}
+
+// imageExtension returns the image:image element for image, its children in
+// the order the image schema requires.
+func imageExtension(image SitemapImage) Extension {
+    child := func(local, value string) Extension {
+        return Extension{XMLName: xml.Name{Space: imageNamespace, Local: local}, Value: value}
+    }
+    children := []Extension{child("loc", image.Loc)}
+    if image.Caption != "" {
+        children = append(children, child("caption", image.Caption))
+    }
+    if image.Title != "" {
+        children = append(children, child("title", image.Title))
+    }
+    return Extension{XMLName: xml.Name{Space: imageNamespace, Local: "image"}, Children: children}
+}
+
+// xhtmlNamespace is the namespace of xhtml:link alternates.
+const xhtmlNamespace = "http://www.w3.org/1999/xhtml"
+
+// withAMPLinks returns urls with an xhtml:link extension added for every
+// AMPURL. The given slice is left untouched.
+func withAMPLinks(urls []SitemapURL) []SitemapURL {
+    var linked []SitemapURL
+    for i, u := range urls {
+        if u.AMPURL == "" {
+            continue
+        }
+        if linked == nil {
+            linked = append([]SitemapURL(nil), urls...)
+        }
+        u.Extensions = append(append([]Extension(nil), u.Extensions...), Extension{
+            XMLName: xml.Name{Space: xhtmlNamespace, Local: "link"},
+            Attrs: []xml.Attr{
+                {Name: xml.Name{Local: "rel"}, Value: "amphtml"},
+                {Name: xml.Name{Local: "href"}, Value: u.AMPURL},
+            },
+        })
+        linked[i] = u
+    }
+    if linked == nil {
+        return urls
+    }
+    return linked
+}
+
+// w3cDateLayouts are the W3C datetime profiles allowed by the sitemap
+// protocol, from least to most precise.
+var w3cDateLayouts = []string{
+    "2006",
+    "2006-01",
+    "2006-01-02",
+    "2006-01-02T15:04Z07:00",
+    "2006-01-02T15:04:05Z07:00",
+    "2006-01-02T15:04:05.999999999Z07:00",
+}
+
+// parseW3CDate parses a date in any of the W3C datetime profiles.
+func parseW3CDate(value string) (time.Time, error) {
+    for _, layout := range w3cDateLayouts {
+        if t, err := time.Parse(layout, value); err == nil {
+            return t, nil
+        }
+    }
+    return time.Time{}, fmt.Errorf("'%s' is not a W3C datetime", value)
+}
+
+// Expires returns an <expires> extension in the given vendor namespace,
+// scheduling the URL's removal at date, which must be a W3C datetime.
+func Expires(namespace string, date string) (Extension, error) {
+    if namespace == "" {
+        return Extension{}, fmt.Errorf("expires extension requires a namespace")
+    }
+    if _, err := parseW3CDate(date); err != nil {
+        return Extension{}, fmt.Errorf("invalid expires date: %v", err)
+    }
+    return Extension{
+        XMLName: xml.Name{Space: namespace, Local: "expires"},
+        Value:   date,
+    }, nil
+}
+
+// ValidateExtensions checks the image, video and news extensions of URLs,
+// including those derived from Images, against Google's documented
+// requirements, returning every violation found. It writes nothing, so
+// problems with the data can be caught before a Write:
+//   - images need a loc
+//   - videos need a thumbnail_loc, a title of at most 100 characters, a
+//     description of at most 2,048 characters and a content_loc or
+//     player_loc; a duration must be between 1 and 28,800 seconds and a
+//     rating between 0.0 and 5.0
+//   - news need a publication with a name and language, a
+//     publication_date and a title
+func (s *SitemapOptions) ValidateExtensions() []error {
+    var errs []error
+    for _, u := range withImages(s.URLs) {
+        counts := map[string]int{}
+        for _, ext := range u.Extensions {
+            var problems []string
+            switch ext.XMLName {
+            case xml.Name{Space: imageNamespace, Local: "image"}:
+                problems = imageProblems(ext)
+            case xml.Name{Space: videoNamespace, Local: "video"}:
+                problems = videoProblems(ext)
+            case xml.Name{Space: newsNamespace, Local: "news"}:
+                problems = newsProblems(ext)
+            default:
+                continue
+            }
+            counts[ext.XMLName.Local]++
+            for _, problem := range problems {
+                errs = append(errs, fmt.Errorf("%s %d of '%s': %s", ext.XMLName.Local, counts[ext.XMLName.Local], u.Loc, problem))
+            }
+        }
+    }
+    return errs
+}
+
+// extensionChild returns the value of the first child of ext named local
+// in its namespace, and whether there is one.
+func extensionChild(ext Extension, local string) (string, bool) {
+    for _, child := range ext.Children {
+        if child.XMLName.Space == ext.XMLName.Space && child.XMLName.Local == local {
+            return strings.TrimSpace(child.Value), true
+        }
+    }
+    return "", false
+}
+
+// requireChildren returns a problem for each of the given children ext lacks.
+func requireChildren(ext Extension, locals ...string) []string {
+    var problems []string
+    for _, local := range locals {
+        if value, ok := extensionChild(ext, local); !ok || value == "" {
+            problems = append(problems, "missing "+local)
+        }
+    }
+    return problems
+}
+
+// imageProblems checks an image:image extension.
+func imageProblems(ext Extension) []string {
+    return requireChildren(ext, "loc")
+}
+
+// videoProblems checks a video:video extension.
+func videoProblems(ext Extension) []string {
+    problems := requireChildren(ext, "thumbnail_loc", "title", "description")
+    _, hasContent := extensionChild(ext, "content_loc")
+    _, hasPlayer := extensionChild(ext, "player_loc")
+    if !hasContent && !hasPlayer {
+        problems = append(problems, "missing content_loc or player_loc")
+    }
+    if title, _ := extensionChild(ext, "title"); utf8.RuneCountInString(title) > 100 {
+        problems = append(problems, fmt.Sprintf("title is %d characters, longer than 100", utf8.RuneCountInString(title)))
+    }
+    if description, _ := extensionChild(ext, "description"); utf8.RuneCountInString(description) > 2048 {
+        problems = append(problems, fmt.Sprintf("description is %d characters, longer than 2048", utf8.RuneCountInString(description)))
+    }
+    if duration, ok := extensionChild(ext, "duration"); ok {
+        if seconds, err := strconv.Atoi(duration); err != nil || seconds < 1 || seconds > 28800 {
+            problems = append(problems, fmt.Sprintf("duration '%s' is not between 1 and 28800 seconds", duration))
+        }
+    }
+    if rating, ok := extensionChild(ext, "rating"); ok {
+        if value, err := strconv.ParseFloat(rating, 64); err != nil || value < 0 || value > 5 {
+            problems = append(problems, fmt.Sprintf("rating '%s' is not between 0.0 and 5.0", rating))
+        }
+    }
+    return problems
+}
+
+// newsProblems checks a news:news extension.
+func newsProblems(ext Extension) []string {
+    var problems []string
+    publication, ok := Extension{}, false
+    for _, child := range ext.Children {
+        if child.XMLName.Space == newsNamespace && child.XMLName.Local == "publication" {
+            publication, ok = child, true
+            break
+        }
+    }
+    if ok {
+        for _, problem := range requireChildren(publication, "name", "language") {
+            problems = append(problems, "publication "+problem)
+        }
+    } else {
+        problems = append(problems, "missing publication")
+    }
+    return append(problems, requireChildren(ext, "publication_date", "title")...)
+}
@@ -0,0 +1,297 @@
+package nyxsitemap
+
+import (
+    "encoding/xml"
+    "os"
+    "path"
+    "strings"
+    "testing"
+)
+
+func TestExpiresExtension(t *testing.T) {
+    dir := "./test_sitemaps_expires"
+    os.RemoveAll(dir)
+    defer os.RemoveAll(dir)
+
+    namespace := "https://crawler.example.com/ns/1.0"
+    expires, err := Expires(namespace, "2030-12-31")
+    if err != nil {
+        t.Fatalf("Error creating expires extension: %v", err)
+    }
+    if _, err := Expires(namespace, "31/12/2030"); err == nil {
+        t.Fatalf("Expected a non-W3C date to be rejected")
+    }
+
+    sm := NewSitemapOptions(dir, "https://www.example.com")
+    sm.AddURL(SitemapURL{Loc: "/promo", Extensions: []Extension{expires}})
+    if err := sm.Write("https://www.example.com/"); err != nil {
+        t.Fatalf("Error writing sitemaps: %v", err)
+    }
+
+    data, err := os.ReadFile(path.Join(dir, "sitemap.xml"))
+    if err != nil {
+        t.Fatalf("Error reading sitemap: %v", err)
+    }
+    element := `<expires xmlns="` + namespace + `">2030-12-31</expires>`
+    if !strings.Contains(string(data), element) {
+        t.Fatalf("Expected %s in sitemap, got:\n%s", element, data)
+    }
+}
+
+func TestAMPURL(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_amp", "https://www.example.com")
+    sm.FS = NewMemFS()
+    sm.AddURL(SitemapURL{Loc: "/article", LastMod: "2023-10-25", AMPURL: "/amp/article"})
+    sm.AddURL(SitemapURL{Loc: "/about", LastMod: "2023-10-25"})
+
+    files, err := sm.WriteToMemory("")
+    if err != nil {
+        t.Fatalf("Error writing sitemap: %v", err)
+    }
+    data := string(files["sitemap.xml"])
+    link := `<xhtml:link rel="amphtml" href="https://www.example.com/amp/article"></xhtml:link>`
+    if !strings.Contains(data, link) {
+        t.Fatalf("Expected an absolutized AMP alternate, got:\n%s", data)
+    }
+    if strings.Count(data, "amphtml") != 1 || strings.Contains(data, "AMPURL") {
+        t.Fatalf("Expected only /article to carry an AMP alternate, got:\n%s", data)
+    }
+}
+
+func TestExtensionNamespaceDeclarations(t *testing.T) {
+    imageNamespace := "http://www.google.com/schemas/sitemap-image/1.1"
+    sm := NewSitemapOptions("./test_sitemaps_ext_namespaces", "https://www.example.com")
+    sm.FS = NewMemFS()
+    sm.SchemaSources = []string{"testdata/sitemap.xsd", "testdata/sitemap-image.xsd"}
+    sm.Validate = hasLibxml2
+    sm.AddURL(SitemapURL{
+        Loc:     "/gallery",
+        LastMod: "2023-10-25",
+        Extensions: []Extension{{
+            XMLName: xml.Name{Space: imageNamespace, Local: "image"},
+            Children: []Extension{{
+                XMLName: xml.Name{Space: imageNamespace, Local: "loc"},
+                Value:   "https://www.example.com/images/1.jpg",
+            }},
+        }},
+    })
+    sm.AddURL(SitemapURL{Loc: "/about", LastMod: "2023-10-25"})
+
+    // Validation against the real sitemap and image schemas runs on write
+    files, err := sm.WriteToMemory("")
+    if err != nil {
+        t.Fatalf("Error writing image sitemap: %v", err)
+    }
+    data := string(files["sitemap.xml"])
+    root := `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9" xmlns:image="` + imageNamespace + `">`
+    if !strings.Contains(data, root) || strings.Count(data, "xmlns") != 2 {
+        t.Fatalf("Expected only the image namespace to be declared on the root, got:\n%s", data)
+    }
+    if !strings.Contains(data, "<image:image>") || !strings.Contains(data, "<image:loc>https://www.example.com/images/1.jpg</image:loc>") {
+        t.Fatalf("Expected prefixed image elements, got:\n%s", data)
+    }
+}
+
+func TestNamespaceOrder(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_ns_order", "https://www.example.com")
+    sm.URLSetAttrs = map[string]string{"xmlns:vendor": "https://vendor.example.com/ns"}
+    sm.AddURL(SitemapURL{Loc: "/", LastMod: "2023-10-25", Extensions: []Extension{
+        {XMLName: xml.Name{Space: xhtmlNamespace, Local: "link"}},
+        {XMLName: xml.Name{Space: newsNamespace, Local: "news"}},
+        {XMLName: xml.Name{Space: videoNamespace, Local: "video"}},
+        {XMLName: xml.Name{Space: imageNamespace, Local: "image"}},
+    }})
+
+    declarations := func() string {
+        data, err := sm.marshalURLSet("sitemap.xml", sm.URLs)
+        if err != nil {
+            t.Fatalf("Error marshaling sitemap: %v", err)
+        }
+        var prefixes []string
+        for _, attr := range strings.Fields(strings.SplitN(string(data), "\n", 4)[2]) {
+            if strings.HasPrefix(attr, "xmlns") {
+                prefixes = append(prefixes, strings.SplitN(attr, "=", 2)[0])
+            }
+        }
+        return strings.Join(prefixes, " ")
+    }
+
+    expected := "xmlns xmlns:image xmlns:video xmlns:news xmlns:xhtml xmlns:vendor"
+    if order := declarations(); order != expected {
+        t.Fatalf("Expected the canonical declaration order %q, got %q", expected, order)
+    }
+
+    sm.NamespaceOrder = []string{"xhtml", "vendor"}
+    expected = "xmlns xmlns:xhtml xmlns:vendor xmlns:image xmlns:news xmlns:video"
+    if order := declarations(); order != expected {
+        t.Fatalf("Expected the configured declaration order %q, got %q", expected, order)
+    }
+}
+
+func TestImages(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_images", "https://www.example.com")
+    sm.FS = NewMemFS()
+    sm.SchemaSources = []string{"testdata/sitemap.xsd", "testdata/sitemap-image.xsd"}
+    sm.Validate = hasLibxml2
+    sm.RequireExtensionSchema = true
+    images := []SitemapImage{
+        {Loc: "/images/1.jpg", Title: "Sunset", Caption: "Sunset over the bay"},
+        {Loc: "https://cdn.example.com/2.jpg"},
+    }
+    sm.AddURL(SitemapURL{Loc: "/gallery", LastMod: "2023-10-25", Images: images})
+    sm.AddURL(SitemapURL{Loc: "/about", LastMod: "2023-10-25"})
+
+    // Validation against the real sitemap and image schemas runs on write
+    files, err := sm.WriteToMemory("")
+    if err != nil {
+        t.Fatalf("Error writing image sitemap: %v", err)
+    }
+    data := string(files["sitemap.xml"])
+    if !strings.Contains(data, `xmlns:image="`+imageNamespace+`"`) {
+        t.Fatalf("Expected the image namespace to be declared, got:\n%s", data)
+    }
+    expected := "<image:image>\n" +
+        "      <image:loc>https://www.example.com/images/1.jpg</image:loc>\n" +
+        "      <image:caption>Sunset over the bay</image:caption>\n" +
+        "      <image:title>Sunset</image:title>\n" +
+        "    </image:image>\n" +
+        "    <image:image>\n" +
+        "      <image:loc>https://cdn.example.com/2.jpg</image:loc>\n" +
+        "    </image:image>"
+    if !strings.Contains(data, expected) {
+        t.Fatalf("Expected nested image elements, got:\n%s", data)
+    }
+    if images[0].Loc != "/images/1.jpg" {
+        t.Fatalf("Expected the caller's images to be left untouched, got %s", images[0].Loc)
+    }
+    if stats := sm.Stats(); stats.Images != 2 {
+        t.Fatalf("Expected 2 images in the stats, got %d", stats.Images)
+    }
+
+    sm.SchemaSources = []string{"testdata/sitemap.xsd"}
+    if _, err := sm.WriteToMemory(""); err == nil || !strings.Contains(err.Error(), imageNamespace) {
+        t.Fatalf("Expected images without an image schema to be rejected, got: %v", err)
+    }
+}
+
+func TestAlternates(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_alternates", "https://www.example.com")
+    sm.FS = NewMemFS()
+    alternates := []Alternate{
+        {Hreflang: "fr", Href: "/fr/pricing"},
+        {Hreflang: "x-default", Href: "https://www.example.com/pricing"},
+    }
+    sm.AddURL(SitemapURL{Loc: "/pricing", LastMod: "2023-10-25", Alternates: alternates})
+
+    // The bundled schema skips the xhtml elements
+    files, err := sm.WriteToMemory("")
+    if err != nil {
+        t.Fatalf("Error writing sitemap with alternates: %v", err)
+    }
+    data := string(files["sitemap.xml"])
+    if !strings.Contains(data, `xmlns:xhtml="`+xhtmlNamespace+`"`) {
+        t.Fatalf("Expected the xhtml namespace to be declared, got:\n%s", data)
+    }
+    for _, link := range []string{
+        `<xhtml:link rel="alternate" hreflang="fr" href="https://www.example.com/fr/pricing"></xhtml:link>`,
+        `<xhtml:link rel="alternate" hreflang="x-default" href="https://www.example.com/pricing"></xhtml:link>`,
+    } {
+        if !strings.Contains(data, link) {
+            t.Fatalf("Expected %s, got:\n%s", link, data)
+        }
+    }
+    if alternates[0].Href != "/fr/pricing" {
+        t.Fatalf("Expected the caller's alternates to be left untouched, got %s", alternates[0].Href)
+    }
+
+    sm.URLs[0].Alternates = []Alternate{{Href: "/de/pricing"}}
+    if _, err := sm.WriteToMemory(""); err == nil || !strings.Contains(err.Error(), "hreflang") {
+        t.Fatalf("Expected an alternate without hreflang to be rejected, got: %v", err)
+    }
+}
+
+func TestDuplicateHreflang(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_duplicate_hreflang", "https://www.example.com")
+    sm.AddURL(SitemapURL{Loc: "/pricing", LastMod: "2023-10-25", Alternates: []Alternate{
+        {Hreflang: "fr", Href: "/fr/pricing"},
+        {Hreflang: "de", Href: "/de/pricing"},
+        {Hreflang: "FR", Href: "/fr-fr/pricing"},
+    }})
+
+    if _, err := sm.WriteToMemory(""); err == nil || !strings.Contains(err.Error(), "repeats hreflang 'FR'") {
+        t.Fatalf("Expected the repeated fr alternate to be rejected by default, got: %v", err)
+    }
+
+    sm.DuplicateHreflang = KeepFirstHreflang
+    files, err := sm.WriteToMemory("")
+    if err != nil {
+        t.Fatalf("Error writing sitemap: %v", err)
+    }
+    data := string(files["sitemap.xml"])
+    if !strings.Contains(data, "https://www.example.com/fr/pricing") || strings.Contains(data, "/fr-fr/pricing") {
+        t.Fatalf("Expected only the first fr alternate to be kept, got:\n%s", data)
+    }
+    if warnings := sm.Warnings(); len(warnings) != 1 || !strings.Contains(warnings[0], "alternate 3") {
+        t.Fatalf("Expected a warning for the dropped alternate, got %v", warnings)
+    }
+}
+
+func TestImageValidation(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_image_validation", "https://www.example.com")
+    sm.FS = NewMemFS()
+    sm.AddURL(SitemapURL{Loc: "/gallery", LastMod: "2023-10-25", Images: []SitemapImage{{Title: "No loc"}}})
+    if _, err := sm.WriteToMemory(""); err == nil || !strings.Contains(err.Error(), "image 1 of 'https://www.example.com/gallery' has no loc") {
+        t.Fatalf("Expected an image without a loc to be rejected, got: %v", err)
+    }
+
+    sm.URLs = nil
+    sm.AddURL(SitemapURL{Loc: "/gallery", LastMod: "2023-10-25", Images: []SitemapImage{
+        {Loc: "images/1.jpg", Caption: strings.Repeat("c", maxImageTextLength+1)},
+    }})
+    files, err := sm.WriteToMemory("")
+    if err != nil {
+        t.Fatalf("Error writing image sitemap: %v", err)
+    }
+    if !strings.Contains(string(files["sitemap.xml"]), "<image:loc>https://www.example.com/images/1.jpg</image:loc>") {
+        t.Fatalf("Expected the relative image loc to be made absolute, got:\n%s", files["sitemap.xml"])
+    }
+    warnings := sm.Warnings()
+    if len(warnings) != 1 || !strings.Contains(warnings[0], "caption") {
+        t.Fatalf("Expected a warning for the long caption, got %v", warnings)
+    }
+}
+
+func TestValidateExtensions(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_validate_extensions", "https://www.example.com")
+    child := func(space, local, value string) Extension {
+        return Extension{XMLName: xml.Name{Space: space, Local: local}, Value: value}
+    }
+    video := Extension{XMLName: xml.Name{Space: videoNamespace, Local: "video"}, Children: []Extension{
+        child(videoNamespace, "thumbnail_loc", "https://www.example.com/thumb.jpg"),
+        child(videoNamespace, "title", strings.Repeat("t", 101)),
+        child(videoNamespace, "content_loc", "https://www.example.com/video.mp4"),
+    }}
+    sm.AddURL(SitemapURL{Loc: "/video", LastMod: "2023-10-25", Extensions: []Extension{video}})
+    sm.AddURL(SitemapURL{Loc: "/gallery", LastMod: "2023-10-25", Images: []SitemapImage{{Loc: "/1.jpg"}, {Title: "No loc"}}})
+
+    errs := sm.ValidateExtensions()
+    expected := []string{
+        "video 1 of '/video': missing description",
+        "video 1 of '/video': title is 101 characters, longer than 100",
+        "image 2 of '/gallery': missing loc",
+    }
+    if len(errs) != len(expected) {
+        t.Fatalf("Expected %d violations, got %v", len(expected), errs)
+    }
+    for i, message := range expected {
+        if errs[i].Error() != message {
+            t.Fatalf("Expected violation %d to be %q, got %q", i, message, errs[i])
+        }
+    }
+
+    sm.URLs = nil
+    sm.AddURL(SitemapURL{Loc: "/gallery", LastMod: "2023-10-25", Images: []SitemapImage{{Loc: "/1.jpg"}}})
+    if errs := sm.ValidateExtensions(); len(errs) != 0 {
+        t.Fatalf("Expected valid extensions to pass, got %v", errs)
+    }
+}
@@ -0,0 +1,60 @@
+package nyxsitemap
+
+import (
+    "mime"
+    "path"
+    "strings"
+)
+
+// FileInfo describes a file generated by Write.
+type FileInfo struct {
+    Name     string            // Path relative to Dir
+    Size     int               // Size in bytes
+    URLCount int               // Number of <url> entries, 0 for index and stylesheet
+    Headers  map[string]string // Resolved FileHeaders plus Content-Type
+}
+
+// Files returns metadata about every file written by the last Write.
+func (s *SitemapOptions) Files() []FileInfo {
+    return s.files
+}
+
+// WriteResult summarizes what the last Write generated.
+type WriteResult struct {
+    Files     []FileInfo // Every generated file, as returned by Files
+    Filenames []string   // Names of the files, relative to Dir
+    URLCount  int        // Total <url> entries across the sitemap files
+    IsIndex   bool       // Whether a sitemap index was written
+}
+
+// Result returns a summary of the files written by the last Write, e.g.
+// for logging the outcome or uploading exactly those files to a CDN.
+func (s *SitemapOptions) Result() WriteResult {
+    result := WriteResult{Files: s.files, IsIndex: s.index != nil}
+    for _, file := range s.files {
+        result.Filenames = append(result.Filenames, file.Name)
+        result.URLCount += file.URLCount
+    }
+    return result
+}
+
+// fileHeaders resolves FileHeaders for the given file. Content-Type is set
+// from the file extension unless FileHeaders overrides it, that of the
+// compressed content for gzipped files.
+func (s *SitemapOptions) fileHeaders(filename string) map[string]string {
+    headers := map[string]string{}
+    switch ext := path.Ext(strings.TrimSuffix(filename, ".gz")); ext {
+    case ".xml":
+        headers["Content-Type"] = "application/xml"
+    case ".xsl":
+        headers["Content-Type"] = "text/xsl"
+    default:
+        if contentType := mime.TypeByExtension(ext); contentType != "" {
+            headers["Content-Type"] = contentType
+        }
+    }
+    for key, value := range s.FileHeaders {
+        headers[key] = strings.ReplaceAll(value, "{filename}", path.Base(filename))
+    }
+    return headers
+}
@@ -0,0 +1,110 @@
+package nyxsitemap
+
+import (
+    "os"
+    "strconv"
+    "testing"
+)
+
+func TestFileHeaders(t *testing.T) {
+    dir := "./test_sitemaps_headers"
+    os.RemoveAll(dir)
+    defer os.RemoveAll(dir)
+
+    sm := NewSitemapOptions(dir, "https://www.example.com")
+    sm.MaxURLs = 2
+    sm.FileHeaders = map[string]string{
+        "Cache-Control":       "public, max-age=3600",
+        "Content-Disposition": `inline; filename="{filename}"`,
+    }
+    for i := 0; i < 3; i++ {
+        sm.AddURL(SitemapURL{Loc: "/page/" + strconv.Itoa(i)})
+    }
+
+    if err := sm.Write("https://www.example.com/"); err != nil {
+        t.Fatalf("Error writing sitemaps: %v", err)
+    }
+
+    files := map[string]FileInfo{}
+    for _, file := range sm.Files() {
+        files[file.Name] = file
+    }
+    if len(files) != 4 {
+        t.Fatalf("Expected 4 generated files, got %d", len(files))
+    }
+
+    shard := files["sitemap_1.xml"]
+    if shard.URLCount != 2 || shard.Size == 0 {
+        t.Fatalf("Unexpected metadata for sitemap_1.xml: %+v", shard)
+    }
+    if shard.Headers["Cache-Control"] != "public, max-age=3600" {
+        t.Fatalf("Expected Cache-Control header, got %q", shard.Headers["Cache-Control"])
+    }
+    if shard.Headers["Content-Disposition"] != `inline; filename="sitemap_1.xml"` {
+        t.Fatalf("Expected resolved Content-Disposition, got %q", shard.Headers["Content-Disposition"])
+    }
+    if shard.Headers["Content-Type"] != "application/xml" {
+        t.Fatalf("Expected application/xml, got %q", shard.Headers["Content-Type"])
+    }
+    if files["sitemap.xsl"].Headers["Content-Type"] != "text/xsl" {
+        t.Fatalf("Expected text/xsl for the stylesheet, got %q", files["sitemap.xsl"].Headers["Content-Type"])
+    }
+}
+
+func TestBytesWritten(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_bytes_written", "https://www.example.com")
+    sm.FS = NewMemFS()
+    sm.MaxURLs = 2
+    for i := 0; i < 5; i++ {
+        sm.AddURL(SitemapURL{Loc: "/page/" + strconv.Itoa(i)})
+    }
+
+    var reported int64
+    calls := 0
+    sm.BytesWritten = func(n int64) {
+        reported += n
+        calls++
+    }
+    if err := sm.Write("https://www.example.com/sitemaps/"); err != nil {
+        t.Fatalf("Error writing sitemaps: %v", err)
+    }
+
+    var total int64
+    for _, file := range sm.Files() {
+        total += int64(file.Size)
+    }
+    if calls != len(sm.Files()) || reported != total {
+        t.Fatalf("Expected %d bytes over %d files, got %d bytes over %d calls", total, len(sm.Files()), reported, calls)
+    }
+}
+
+func TestWriteResult(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_result", "https://www.example.com")
+    sm.FS = NewMemFS()
+    sm.MaxURLs = 2
+    for i := 0; i < 3; i++ {
+        sm.AddURL(SitemapURL{Loc: "/page/" + strconv.Itoa(i)})
+    }
+    if err := sm.Write("https://www.example.com/"); err != nil {
+        t.Fatalf("Error writing sitemaps: %v", err)
+    }
+
+    result := sm.Result()
+    if !result.IsIndex || result.URLCount != 3 || len(result.Files) != 4 {
+        t.Fatalf("Unexpected result for an index run: %+v", result)
+    }
+    expected := []string{"sitemap.xsl", "sitemap_1.xml", "sitemap_2.xml", "sitemap_index.xml"}
+    for i, name := range expected {
+        if result.Filenames[i] != name {
+            t.Fatalf("Expected file %d to be %s, got %v", i, name, result.Filenames)
+        }
+    }
+
+    sm.MaxURLs = 10
+    if err := sm.Write("https://www.example.com/"); err != nil {
+        t.Fatalf("Error writing sitemap: %v", err)
+    }
+    if result := sm.Result(); result.IsIndex || result.URLCount != 3 || len(result.Filenames) != 2 {
+        t.Fatalf("Unexpected result for a single sitemap run: %+v", result)
+    }
+}
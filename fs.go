@@ -0,0 +1,306 @@
+package nyxsitemap
+
+import (
+    "errors"
+    "fmt"
+    "io/fs"
+    "os"
+    "path"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+)
+
+// FileSystem is where sitemap files are written to and read back from for
+// validation. Names passed to it are already joined with Dir.
+type FileSystem interface {
+    MkdirAll(path string, perm os.FileMode) error
+    WriteFile(name string, data []byte, perm os.FileMode) error
+    ReadFile(name string) ([]byte, error)
+    Stat(name string) (os.FileInfo, error)
+}
+
+// osFS is the default FileSystem backed by the local disk.
+type osFS struct{}
+
+func (osFS) MkdirAll(path string, perm os.FileMode) error {
+    return os.MkdirAll(path, perm)
+}
+
+func (osFS) WriteFile(name string, data []byte, perm os.FileMode) error {
+    return os.WriteFile(name, data, perm)
+}
+
+func (osFS) ReadFile(name string) ([]byte, error) {
+    return os.ReadFile(name)
+}
+
+func (osFS) Stat(name string) (os.FileInfo, error) {
+    return os.Stat(name)
+}
+
+func (osFS) ListFiles(dir string) ([]string, error) {
+    var names []string
+    err := filepath.WalkDir(dir, func(name string, entry fs.DirEntry, err error) error {
+        if err != nil || entry.IsDir() {
+            return err
+        }
+        rel, err := filepath.Rel(dir, name)
+        names = append(names, filepath.ToSlash(rel))
+        return err
+    })
+    return names, err
+}
+
+// FileLister is implemented by FileSystems able to list the files under a
+// directory, which RequireCleanDir needs. Both the default FileSystem and
+// MemFS implement it.
+type FileLister interface {
+    // ListFiles returns the names of the files under dir, recursively,
+    // relative to dir. A missing dir is reported as fs.ErrNotExist.
+    ListFiles(dir string) ([]string, error)
+}
+
+// Renamer is implemented by FileSystems able to rename a file, which Edit
+// uses to replace files atomically. Both the default FileSystem and MemFS
+// implement it; Edit overwrites files in place on the others.
+type Renamer interface {
+    Rename(oldname, newname string) error
+}
+
+func (osFS) Rename(oldname, newname string) error {
+    return os.Rename(oldname, newname)
+}
+
+// MemFS is an in-memory FileSystem, useful for tests and for generating
+// sitemaps without touching the disk.
+type MemFS struct {
+    mu    sync.Mutex
+    files map[string][]byte
+    dirs  map[string]bool
+}
+
+// NewMemFS initializes an empty MemFS.
+func NewMemFS() *MemFS {
+    return &MemFS{files: map[string][]byte{}, dirs: map[string]bool{".": true, "/": true}}
+}
+
+// MkdirAll records dir and all of its parents as existing directories.
+func (m *MemFS) MkdirAll(dir string, perm os.FileMode) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    m.addDirs(dir)
+    return nil
+}
+
+// WriteFile stores a copy of data under name, creating its parent
+// directories implicitly.
+func (m *MemFS) WriteFile(name string, data []byte, perm os.FileMode) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    m.files[path.Clean(name)] = append([]byte(nil), data...)
+    m.addDirs(path.Dir(name))
+    return nil
+}
+
+// addDirs records dir and its parents. The caller must hold m.mu.
+func (m *MemFS) addDirs(dir string) {
+    for dir = path.Clean(dir); !m.dirs[dir]; dir = path.Dir(dir) {
+        m.dirs[dir] = true
+    }
+}
+
+// Stat describes the file or directory stored under name.
+func (m *MemFS) Stat(name string) (os.FileInfo, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    name = path.Clean(name)
+    if data, ok := m.files[name]; ok {
+        return memFileInfo{name: path.Base(name), size: int64(len(data))}, nil
+    }
+    if m.dirs[name] {
+        return memFileInfo{name: path.Base(name), dir: true}, nil
+    }
+    return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+}
+
+// ReadFile returns a copy of the data stored under name.
+func (m *MemFS) ReadFile(name string) ([]byte, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    data, ok := m.files[path.Clean(name)]
+    if !ok {
+        return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+    }
+    return append([]byte(nil), data...), nil
+}
+
+// Rename moves the data stored under oldname to newname.
+func (m *MemFS) Rename(oldname, newname string) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    data, ok := m.files[path.Clean(oldname)]
+    if !ok {
+        return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: os.ErrNotExist}
+    }
+    delete(m.files, path.Clean(oldname))
+    m.files[path.Clean(newname)] = data
+    m.addDirs(path.Dir(newname))
+    return nil
+}
+
+// memFileInfo is the os.FileInfo returned by MemFS.Stat.
+type memFileInfo struct {
+    name string
+    size int64
+    dir  bool
+}
+
+func (fi memFileInfo) Name() string       { return fi.name }
+func (fi memFileInfo) Size() int64        { return fi.size }
+func (fi memFileInfo) ModTime() time.Time { return time.Time{} }
+func (fi memFileInfo) IsDir() bool        { return fi.dir }
+func (fi memFileInfo) Sys() interface{}   { return nil }
+
+func (fi memFileInfo) Mode() os.FileMode {
+    if fi.dir {
+        return os.ModeDir | 0755
+    }
+    return 0644
+}
+
+// ListFiles returns the names of the files stored under dir, relative to it.
+func (m *MemFS) ListFiles(dir string) ([]string, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    dir = path.Clean(dir)
+    if !m.dirs[dir] {
+        return nil, &os.PathError{Op: "open", Path: dir, Err: os.ErrNotExist}
+    }
+    prefix := strings.TrimSuffix(dir, "/") + "/"
+    var names []string
+    for name := range m.files {
+        if dir == "." {
+            names = append(names, name)
+        } else if rel, ok := strings.CutPrefix(name, prefix); ok {
+            names = append(names, rel)
+        }
+    }
+    sort.Strings(names)
+    return names, nil
+}
+
+// Names returns the names of all stored files in sorted order.
+func (m *MemFS) Names() []string {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    names := make([]string, 0, len(m.files))
+    for name := range m.files {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// fs returns the configured FileSystem, defaulting to the local disk.
+func (s *SitemapOptions) fs() FileSystem {
+    if s.FS == nil {
+        return osFS{}
+    }
+    return s.FS
+}
+
+// ensureDir creates Dir, or with CreateDir disabled checks that it exists.
+func (s *SitemapOptions) ensureDir() error {
+    if s.RequireCleanDir {
+        if err := s.checkCleanDir(); err != nil {
+            return err
+        }
+    }
+    if s.CreateDir {
+        return s.fs().MkdirAll(s.Dir, 0755)
+    }
+    if info, err := s.fs().Stat(s.Dir); err != nil || !info.IsDir() {
+        return fmt.Errorf("sitemap directory '%s' does not exist", s.Dir)
+    }
+    return nil
+}
+
+// managedFilePattern matches the names Write gives sitemap files by default.
+var managedFilePattern = regexp.MustCompile(`^sitemap(_index|_\d+)?\.xml(\.gz)?$`)
+
+// checkCleanDir checks that Dir, if it exists, only holds files Write could
+// have generated, going by their names.
+func (s *SitemapOptions) checkCleanDir() error {
+    lister, ok := s.fs().(FileLister)
+    if !ok {
+        return fmt.Errorf("RequireCleanDir needs a FileSystem implementing FileLister")
+    }
+    names, err := lister.ListFiles(s.Dir)
+    if errors.Is(err, fs.ErrNotExist) {
+        return nil
+    }
+    if err != nil {
+        return fmt.Errorf("failed to list sitemap directory '%s': %v", s.Dir, err)
+    }
+    for _, name := range names {
+        base := path.Base(name)
+        if !managedFilePattern.MatchString(base) && base != s.Stylesheet {
+            return fmt.Errorf("sitemap directory '%s' holds '%s', which is not a sitemap file", s.Dir, name)
+        }
+    }
+    return nil
+}
+
+// mirrorFiles copies the files written by the last run from Dir into each
+// of Dirs.
+func (s *SitemapOptions) mirrorFiles() error {
+    for _, dir := range s.Dirs {
+        if !s.CreateDir {
+            if info, err := s.fs().Stat(dir); err != nil || !info.IsDir() {
+                return fmt.Errorf("sitemap directory '%s' does not exist", dir)
+            }
+        }
+        for _, file := range s.files {
+            data, err := s.fs().ReadFile(path.Join(s.Dir, file.Name))
+            if err != nil {
+                return err
+            }
+            filePath := path.Join(dir, file.Name)
+            if err := s.fs().MkdirAll(path.Dir(filePath), 0755); err != nil {
+                return err
+            }
+            if err := s.fs().WriteFile(filePath, data, 0644); err != nil {
+                return err
+            }
+        }
+    }
+    return nil
+}
+
+// WriteToMemory runs Write against an in-memory FileSystem and returns the
+// whole generated tree, keyed by filename relative to Dir.
+func (s *SitemapOptions) WriteToMemory(baseSitemapURL string) (map[string][]byte, error) {
+    memFS := NewMemFS()
+    previous := s.FS
+    s.FS = memFS
+    defer func() { s.FS = previous }()
+
+    if err := s.Write(baseSitemapURL); err != nil {
+        return nil, err
+    }
+
+    prefix := path.Clean(s.Dir) + "/"
+    files := map[string][]byte{}
+    for _, name := range memFS.Names() {
+        data, err := memFS.ReadFile(name)
+        if err != nil {
+            return nil, err
+        }
+        files[strings.TrimPrefix(name, prefix)] = data
+    }
+    return files, nil
+}
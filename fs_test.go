@@ -0,0 +1,172 @@
+package nyxsitemap
+
+import (
+    "bytes"
+    "embed"
+    "io/fs"
+    "os"
+    "path"
+    "strings"
+    "testing"
+)
+
+//go:embed testdata/golden
+var goldenFiles embed.FS
+
+func TestWriteToMemoryMatchesGolden(t *testing.T) {
+    dir := "./test_sitemaps_memory"
+    sm := NewSitemapOptions(dir, "https://www.example.com")
+    sm.AddURL(SitemapURL{Loc: "/", LastMod: "2023-10-25", ChangeFreq: "daily", Priority: "1.0"})
+    sm.AddURL(SitemapURL{Loc: "/about", LastMod: "2023-09-01", ChangeFreq: "monthly", Priority: "0.8"})
+
+    files, err := sm.WriteToMemory("https://www.example.com/")
+    if err != nil {
+        t.Fatalf("Error writing sitemaps to memory: %v", err)
+    }
+    if _, err := os.Stat(dir); !os.IsNotExist(err) {
+        t.Fatalf("Expected nothing to be written to disk")
+    }
+
+    golden, err := fs.Sub(goldenFiles, "testdata/golden")
+    if err != nil {
+        t.Fatalf("Error opening golden files: %v", err)
+    }
+    entries, err := fs.ReadDir(golden, ".")
+    if err != nil {
+        t.Fatalf("Error listing golden files: %v", err)
+    }
+    if len(entries) != len(files) {
+        t.Fatalf("Expected %d files, got %d", len(entries), len(files))
+    }
+    for _, entry := range entries {
+        expected, _ := fs.ReadFile(golden, entry.Name())
+        if !bytes.Equal(files[entry.Name()], expected) {
+            t.Fatalf("Generated %s does not match golden file:\n%s", entry.Name(), files[entry.Name()])
+        }
+    }
+}
+
+func TestValidateBytes(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_validate_bytes", "https://www.example.com")
+    sm.MaxURLs = 1
+    sm.AddURL(SitemapURL{Loc: "/a", LastMod: "2023-10-25"})
+    sm.AddURL(SitemapURL{Loc: "/b", LastMod: "2023-10-25"})
+
+    files, err := sm.WriteToMemory("https://www.example.com/")
+    if err != nil {
+        t.Fatalf("Error writing sitemaps to memory: %v", err)
+    }
+    if err := sm.ValidateBytes(files["sitemap_index.xml"], true); err != nil {
+        t.Fatalf("Expected the index to validate: %v", err)
+    }
+    if err := sm.ValidateBytes(files["sitemap_1.xml"], false); err != nil {
+        t.Fatalf("Expected the urlset to validate: %v", err)
+    }
+
+    // Each document only validates against its own schema
+    if err := sm.ValidateBytes(files["sitemap_1.xml"], true); err == nil {
+        t.Fatalf("Expected a urlset to fail index validation")
+    }
+    if err := sm.ValidateBytes(files["sitemap_index.xml"], false); err == nil {
+        t.Fatalf("Expected an index to fail urlset validation")
+    }
+}
+
+func TestCreateDir(t *testing.T) {
+    dir := "./test_sitemaps_createdir"
+    os.RemoveAll(dir)
+    defer os.RemoveAll(dir)
+
+    sm := NewSitemapOptions(dir, "https://www.example.com")
+    sm.CreateDir = false
+    sm.AddURL(SitemapURL{Loc: "/"})
+
+    err := sm.Write("https://www.example.com/")
+    if err == nil {
+        t.Fatalf("Expected an error for a missing directory")
+    }
+    if _, statErr := os.Stat(dir); !os.IsNotExist(statErr) {
+        t.Fatalf("Expected the directory not to be created")
+    }
+
+    sm.CreateDir = true
+    if err := sm.Write("https://www.example.com/"); err != nil {
+        t.Fatalf("Error writing sitemaps: %v", err)
+    }
+    if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+        t.Fatalf("Expected the directory to be created")
+    }
+}
+
+func TestMirrorDirs(t *testing.T) {
+    primary := t.TempDir()
+    backup := t.TempDir()
+
+    sm := NewSitemapOptions(primary, "https://www.example.com")
+    sm.Dirs = []string{backup}
+    sm.MaxURLs = 2
+    for _, loc := range []string{"/a", "/b", "/c"} {
+        sm.AddURL(SitemapURL{Loc: loc})
+    }
+    if err := sm.Write("https://www.example.com/"); err != nil {
+        t.Fatalf("Error writing sitemaps: %v", err)
+    }
+
+    for _, name := range []string{"sitemap_index.xml", "sitemap_1.xml", "sitemap_2.xml", "sitemap.xsl"} {
+        want, err := os.ReadFile(path.Join(primary, name))
+        if err != nil {
+            t.Fatalf("Expected %s in primary dir: %v", name, err)
+        }
+        got, err := os.ReadFile(path.Join(backup, name))
+        if err != nil {
+            t.Fatalf("Expected %s in backup dir: %v", name, err)
+        }
+        if !bytes.Equal(got, want) {
+            t.Fatalf("%s differs between primary and backup", name)
+        }
+    }
+
+    mirrored := NewSitemapOptions(backup, "https://www.example.com")
+    if err := mirrored.validateSitemapIndexAndFiles("https://www.example.com/"); err != nil {
+        t.Fatalf("Backup files do not validate: %v", err)
+    }
+}
+
+func TestRequireCleanDir(t *testing.T) {
+    dir := "./test_sitemaps_clean_dir"
+    os.RemoveAll(dir)
+    defer os.RemoveAll(dir)
+
+    sm := NewSitemapOptions(dir, "https://www.example.com")
+    sm.RequireCleanDir = true
+    sm.DirLayout = ByYear
+    sm.AddURL(SitemapURL{Loc: "/a", LastMod: "2022-05-01"})
+    sm.AddURL(SitemapURL{Loc: "/b", LastMod: "2023-05-01"})
+
+    // A missing directory counts as clean, and so does the previous output
+    for i := 0; i < 2; i++ {
+        if err := sm.Write("https://www.example.com/"); err != nil {
+            t.Fatalf("Error writing sitemaps into a clean directory: %v", err)
+        }
+    }
+
+    if err := os.WriteFile(path.Join(dir, "2023", "notes.txt"), []byte("keep"), 0644); err != nil {
+        t.Fatalf("Error writing unrelated file: %v", err)
+    }
+    os.Remove(path.Join(dir, "sitemap_index.xml"))
+    err := sm.Write("https://www.example.com/")
+    if err == nil || !strings.Contains(err.Error(), "2023/notes.txt") {
+        t.Fatalf("Expected the unrelated file to be reported, got: %v", err)
+    }
+    if _, err := os.Stat(path.Join(dir, "sitemap_index.xml")); !os.IsNotExist(err) {
+        t.Fatalf("Expected nothing to be written into an unclean directory")
+    }
+
+    // MemFS can be listed too
+    memFS := NewMemFS()
+    memFS.WriteFile(path.Join(dir, "index.html"), []byte("<html>"), 0644)
+    sm.FS = memFS
+    if err := sm.Write("https://www.example.com/"); err == nil || !strings.Contains(err.Error(), "index.html") {
+        t.Fatalf("Expected the unrelated in-memory file to be reported, got: %v", err)
+    }
+}
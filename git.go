@@ -0,0 +1,46 @@
+package nyxsitemap
+
+import (
+    "os"
+    "os/exec"
+    "path/filepath"
+    "strings"
+    "time"
+)
+
+// AddURLFromGit adds loc with its lastmod set to the last commit touching
+// filePath in the Git repository at repoPath. When Git is unavailable or the
+// file has no commits, it falls back to the file's modification time, and
+// then to the current date. The lastmod is that time's date in TimeZone.
+func (s *SitemapOptions) AddURLFromGit(loc string, repoPath string, filePath string) error {
+    if _, err := s.resolveURL(loc); err != nil {
+        return err
+    }
+
+    lastMod, ok := gitLastCommitTime(repoPath, filePath)
+    if !ok {
+        if info, err := os.Stat(filepath.Join(repoPath, filePath)); err == nil {
+            lastMod, ok = info.ModTime(), true
+        }
+    }
+
+    url := SitemapURL{Loc: loc}
+    if ok {
+        url.LastMod = s.date(lastMod)
+    }
+    return s.AddURL(url)
+}
+
+// gitLastCommitTime returns the committer date of the last commit touching
+// filePath in the repository at repoPath.
+func gitLastCommitTime(repoPath string, filePath string) (time.Time, bool) {
+    out, err := exec.Command("git", "-C", repoPath, "log", "-1", "--format=%cI", "--", filePath).Output()
+    if err != nil {
+        return time.Time{}, false
+    }
+    commitTime, err := time.Parse(time.RFC3339, strings.TrimSpace(string(out)))
+    if err != nil {
+        return time.Time{}, false
+    }
+    return commitTime, true
+}
@@ -0,0 +1,61 @@
+package nyxsitemap
+
+import (
+    "os"
+    "os/exec"
+    "path/filepath"
+    "testing"
+    "time"
+)
+
+func TestAddURLFromGit(t *testing.T) {
+    if _, err := exec.LookPath("git"); err != nil {
+        t.Skip("git is not installed")
+    }
+
+    repo := t.TempDir()
+    run := func(args ...string) {
+        cmd := exec.Command("git", append([]string{"-C", repo}, args...)...)
+        cmd.Env = append(os.Environ(),
+            "GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+            "GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+            "GIT_AUTHOR_DATE=2022-06-15T10:00:00+00:00",
+            "GIT_COMMITTER_DATE=2022-06-15T10:00:00+00:00",
+        )
+        if out, err := cmd.CombinedOutput(); err != nil {
+            t.Fatalf("git %v failed: %v\n%s", args, err, out)
+        }
+    }
+    run("init", "-q")
+    os.WriteFile(filepath.Join(repo, "about.md"), []byte("# About"), 0644)
+    run("add", "about.md")
+    run("commit", "-q", "-m", "Add about page")
+
+    untracked := filepath.Join(repo, "draft.md")
+    os.WriteFile(untracked, []byte("# Draft"), 0644)
+    mtime := time.Date(2021, 3, 4, 12, 0, 0, 0, time.UTC)
+    os.Chtimes(untracked, mtime, mtime)
+
+    sm := NewSitemapOptions("./test_sitemaps_git", "https://www.example.com")
+    if err := sm.AddURLFromGit("/about", repo, "about.md"); err != nil {
+        t.Fatalf("Error adding URL from git: %v", err)
+    }
+    if err := sm.AddURLFromGit("/draft", repo, "draft.md"); err != nil {
+        t.Fatalf("Error adding URL from git: %v", err)
+    }
+
+    if sm.URLs[0].LastMod != "2022-06-15" {
+        t.Fatalf("Expected commit date 2022-06-15, got %s", sm.URLs[0].LastMod)
+    }
+    if sm.URLs[1].LastMod != "2021-03-04" {
+        t.Fatalf("Expected mtime fallback 2021-03-04, got %s", sm.URLs[1].LastMod)
+    }
+
+    sm.TimeZone = time.FixedZone("UTC+14", 14*60*60)
+    if err := sm.AddURLFromGit("/about-local", repo, "about.md"); err != nil {
+        t.Fatalf("Error adding URL from git: %v", err)
+    }
+    if sm.URLs[2].LastMod != "2022-06-16" {
+        t.Fatalf("Expected commit date 2022-06-16 in TimeZone, got %s", sm.URLs[2].LastMod)
+    }
+}
@@ -0,0 +1,43 @@
+package nyxsitemap
+
+import (
+    "bytes"
+    "compress/gzip"
+    "io"
+    "time"
+)
+
+// isGzip reports whether data starts with the gzip magic number.
+func isGzip(data []byte) bool {
+    return len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b
+}
+
+// gunzipIfNeeded decompresses gzip data and returns anything else unchanged.
+func gunzipIfNeeded(data []byte) ([]byte, error) {
+    if !isGzip(data) {
+        return data, nil
+    }
+    reader, err := gzip.NewReader(bytes.NewReader(data))
+    if err != nil {
+        return nil, err
+    }
+    defer reader.Close()
+    return io.ReadAll(reader)
+}
+
+// gzipBytes compresses data. The header carries no modification time, name
+// or comment, so identical content always compresses to identical bytes.
+func gzipBytes(data []byte) ([]byte, error) {
+    var buffer bytes.Buffer
+    writer := gzip.NewWriter(&buffer)
+    writer.ModTime = time.Time{}
+    writer.Name = ""
+    writer.Comment = ""
+    if _, err := writer.Write(data); err != nil {
+        return nil, err
+    }
+    if err := writer.Close(); err != nil {
+        return nil, err
+    }
+    return buffer.Bytes(), nil
+}
@@ -0,0 +1,122 @@
+package nyxsitemap
+
+import (
+    "bytes"
+    "os"
+    "path"
+    "strings"
+    "testing"
+)
+
+func TestGzipDeterministic(t *testing.T) {
+    data := []byte(`<?xml version="1.0" encoding="UTF-8"?>` + "\n<urlset></urlset>\n")
+
+    first, err := gzipBytes(data)
+    if err != nil {
+        t.Fatalf("Error compressing: %v", err)
+    }
+    second, _ := gzipBytes(data)
+    if !bytes.Equal(first, second) {
+        t.Fatalf("Expected identical content to compress to identical bytes")
+    }
+
+    // No modification time in the header
+    if !bytes.Equal(first[4:8], []byte{0, 0, 0, 0}) {
+        t.Fatalf("Expected a zero gzip header mtime, got %v", first[4:8])
+    }
+    out, err := gunzipIfNeeded(first)
+    if err != nil || !bytes.Equal(out, data) {
+        t.Fatalf("Expected the compressed data to round-trip, got %q, %v", out, err)
+    }
+}
+
+func TestGzipOutput(t *testing.T) {
+    dir := "./test_sitemaps_gzip"
+    os.RemoveAll(dir)
+    defer os.RemoveAll(dir)
+
+    sm := NewSitemapOptions(dir, "https://www.example.com")
+    sm.MaxURLs = 2
+    sm.Gzip = true
+    for _, loc := range []string{"/a", "/b", "/c"} {
+        sm.AddURL(SitemapURL{Loc: loc, LastMod: "2023-10-25"})
+    }
+
+    // Write validates the decompressed files
+    if err := sm.Write("https://www.example.com/"); err != nil {
+        t.Fatalf("Error writing gzipped sitemaps: %v", err)
+    }
+    for _, name := range []string{"sitemap_1.xml.gz", "sitemap_2.xml.gz"} {
+        data, err := os.ReadFile(path.Join(dir, name))
+        if err != nil || !isGzip(data) {
+            t.Fatalf("Expected %s to be written gzipped: %v", name, err)
+        }
+    }
+    index, err := os.ReadFile(path.Join(dir, "sitemap_index.xml"))
+    if err != nil || isGzip(index) {
+        t.Fatalf("Expected a plain sitemap_index.xml without GzipIndex: %v", err)
+    }
+    if !strings.Contains(string(index), "<loc>https://www.example.com/sitemap_1.xml.gz</loc>") {
+        t.Fatalf("Expected the index to reference the .gz files:\n%s", index)
+    }
+
+    urls, err := ReadIndexURLs(path.Join(dir, "sitemap_index.xml"))
+    if err != nil || len(urls) != 3 {
+        t.Fatalf("Expected to read the 3 URLs back through the index, got %d: %v", len(urls), err)
+    }
+
+    sm.GzipIndex = true
+    if err := sm.Write("https://www.example.com/"); err != nil {
+        t.Fatalf("Error writing gzipped index: %v", err)
+    }
+    index, err = os.ReadFile(path.Join(dir, "sitemap_index.xml.gz"))
+    if err != nil || !isGzip(index) {
+        t.Fatalf("Expected sitemap_index.xml.gz to be written gzipped: %v", err)
+    }
+
+    single := NewSitemapOptions(dir, "https://www.example.com")
+    single.Gzip = true
+    single.AddURL(SitemapURL{Loc: "/", LastMod: "2023-10-25"})
+    if err := single.Write(""); err != nil {
+        t.Fatalf("Error writing gzipped sitemap: %v", err)
+    }
+    if files := single.Files(); files[len(files)-1].Name != "sitemap.xml.gz" || files[len(files)-1].Headers["Content-Type"] != "application/xml" {
+        t.Fatalf("Expected sitemap.xml.gz served as XML, got %+v", files[len(files)-1])
+    }
+}
+
+func TestGzipKeepExtension(t *testing.T) {
+    for _, keep := range []bool{false, true} {
+        fs := NewMemFS()
+        sm := NewSitemapOptions("./test_sitemaps_gzip_ext", "https://www.example.com")
+        sm.FS = fs
+        sm.MaxURLs = 1
+        sm.Gzip = true
+        sm.GzipIndex = true
+        sm.GzipKeepExtension = keep
+        sm.AddURL(SitemapURL{Loc: "/a", LastMod: "2023-10-25"})
+        sm.AddURL(SitemapURL{Loc: "/b", LastMod: "2023-10-25"})
+        if err := sm.Write("https://www.example.com/"); err != nil {
+            t.Fatalf("Error writing gzipped sitemaps with keep=%v: %v", keep, err)
+        }
+
+        suffix := ".gz"
+        if keep {
+            suffix = ""
+        }
+        index, err := fs.ReadFile("test_sitemaps_gzip_ext/sitemap_index.xml" + suffix)
+        if err != nil || !isGzip(index) {
+            t.Fatalf("Expected a gzipped sitemap_index.xml%s with keep=%v: %v", suffix, keep, err)
+        }
+        index, _ = gunzipIfNeeded(index)
+        for _, name := range []string{"sitemap_1.xml" + suffix, "sitemap_2.xml" + suffix} {
+            data, err := fs.ReadFile("test_sitemaps_gzip_ext/" + name)
+            if err != nil || !isGzip(data) {
+                t.Fatalf("Expected a gzipped %s with keep=%v: %v", name, keep, err)
+            }
+            if !strings.Contains(string(index), "<loc>https://www.example.com/"+name+"</loc>") {
+                t.Fatalf("Expected the index to reference %s with keep=%v:\n%s", name, keep, index)
+            }
+        }
+    }
+}
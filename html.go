@@ -0,0 +1,37 @@
+package nyxsitemap
+
+import (
+    "html"
+    "path"
+    "strings"
+)
+
+// WriteHTML writes a plain HTML page titled title at filePath, listing a
+// link to every resolved URL for human visitors. At most HTMLMaxEntries
+// links are listed when it is set.
+func (s *SitemapOptions) WriteHTML(filePath string, title string) error {
+    urls, err := s.resolvedURLs()
+    if err != nil {
+        return err
+    }
+    if s.HTMLMaxEntries > 0 && len(urls) > s.HTMLMaxEntries {
+        urls = urls[:s.HTMLMaxEntries]
+    }
+
+    var b strings.Builder
+    b.WriteString("<!DOCTYPE html>\n<html>\n<head>\n")
+    b.WriteString(`<meta charset="utf-8">` + "\n")
+    b.WriteString("<title>" + html.EscapeString(title) + "</title>\n")
+    b.WriteString("</head>\n<body>\n")
+    b.WriteString("<h1>" + html.EscapeString(title) + "</h1>\n<ul>\n")
+    for _, u := range urls {
+        loc := html.EscapeString(u.Loc)
+        b.WriteString(`<li><a href="` + loc + `">` + loc + "</a></li>\n")
+    }
+    b.WriteString("</ul>\n</body>\n</html>\n")
+
+    if err := s.fs().MkdirAll(path.Dir(filePath), 0755); err != nil {
+        return err
+    }
+    return s.fs().WriteFile(filePath, []byte(b.String()), 0644)
+}
@@ -0,0 +1,45 @@
+package nyxsitemap
+
+import (
+    "strings"
+    "testing"
+)
+
+func TestWriteHTML(t *testing.T) {
+    memFS := NewMemFS()
+    sm := NewSitemapOptions("./test_sitemaps_html", "https://www.example.com")
+    sm.FS = memFS
+    sm.AddURL(SitemapURL{Loc: "/"})
+    sm.AddURL(SitemapURL{Loc: "/about"})
+    sm.AddURL(SitemapURL{Loc: "/search?q=a&b=c"})
+
+    if err := sm.WriteHTML("test_sitemaps_html/sitemap.html", "Site & Map"); err != nil {
+        t.Fatalf("Error writing HTML sitemap: %v", err)
+    }
+    data, err := memFS.ReadFile("test_sitemaps_html/sitemap.html")
+    if err != nil {
+        t.Fatalf("Expected sitemap.html to be written: %v", err)
+    }
+    page := string(data)
+    for _, anchor := range []string{
+        `<a href="https://www.example.com/">`,
+        `<a href="https://www.example.com/about">`,
+        `<a href="https://www.example.com/search?q=a&amp;b=c">`,
+    } {
+        if !strings.Contains(page, anchor) {
+            t.Fatalf("Expected %s in HTML sitemap, got:\n%s", anchor, page)
+        }
+    }
+    if !strings.Contains(page, "<title>Site &amp; Map</title>") {
+        t.Fatalf("Expected an escaped title, got:\n%s", page)
+    }
+
+    sm.HTMLMaxEntries = 2
+    if err := sm.WriteHTML("test_sitemaps_html/sitemap.html", "Site"); err != nil {
+        t.Fatalf("Error writing HTML sitemap: %v", err)
+    }
+    data, _ = memFS.ReadFile("test_sitemaps_html/sitemap.html")
+    if count := strings.Count(string(data), "<a href="); count != 2 {
+        t.Fatalf("Expected HTMLMaxEntries to cap the links at 2, got %d", count)
+    }
+}
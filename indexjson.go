@@ -0,0 +1,16 @@
+package nyxsitemap
+
+import (
+    "encoding/json"
+    "errors"
+)
+
+// IndexJSON returns the sitemap index written by the last Write as a JSON
+// array of {"loc", "lastmod"} entries, for dashboards and other tooling.
+// It fails if the last run wrote a single sitemap instead of an index.
+func (s *SitemapOptions) IndexJSON() ([]byte, error) {
+    if s.index == nil {
+        return nil, errors.New("no sitemap index was written by the last run")
+    }
+    return json.Marshal(s.index.Sitemaps)
+}
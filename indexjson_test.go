@@ -0,0 +1,43 @@
+package nyxsitemap
+
+import (
+    "encoding/json"
+    "testing"
+)
+
+func TestIndexJSON(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_index_json", "https://www.example.com")
+    sm.FS = NewMemFS()
+    sm.MaxURLs = 1
+    sm.Canonical = true
+    sm.AddURL(SitemapURL{Loc: "/a", LastMod: "2023-10-24"})
+    sm.AddURL(SitemapURL{Loc: "/b", LastMod: "2023-10-25"})
+
+    if _, err := sm.IndexJSON(); err == nil {
+        t.Fatalf("Expected an error before any index was written")
+    }
+    if err := sm.Write("https://www.example.com/sitemaps/"); err != nil {
+        t.Fatalf("Error writing sitemaps: %v", err)
+    }
+
+    data, err := sm.IndexJSON()
+    if err != nil {
+        t.Fatalf("Error serializing index: %v", err)
+    }
+    var entries []map[string]string
+    if err := json.Unmarshal(data, &entries); err != nil {
+        t.Fatalf("Index JSON does not parse: %v\n%s", err, data)
+    }
+    expected := []map[string]string{
+        {"loc": "https://www.example.com/sitemaps/sitemap_1.xml", "lastmod": "2023-10-24"},
+        {"loc": "https://www.example.com/sitemaps/sitemap_2.xml", "lastmod": "2023-10-25"},
+    }
+    if len(entries) != len(expected) {
+        t.Fatalf("Expected %d entries, got %s", len(expected), data)
+    }
+    for i, entry := range entries {
+        if len(entry) != 2 || entry["loc"] != expected[i]["loc"] || entry["lastmod"] != expected[i]["lastmod"] {
+            t.Fatalf("Unexpected entry %d: %v", i, entry)
+        }
+    }
+}
@@ -0,0 +1,74 @@
+package nyxsitemap
+
+import (
+    "runtime"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+// concurrency returns how many shards are written or validated at once.
+func (s *SitemapOptions) concurrency() int {
+    if s.Concurrency > 0 {
+        return s.Concurrency
+    }
+    return runtime.GOMAXPROCS(0)
+}
+
+// forEachShard calls fn for shards 0 to n-1 on up to Concurrency workers.
+// Each call gets its own run of s to record files, stats and warnings in,
+// merged back into s in shard order, so the outcome doesn't depend on
+// scheduling. Phase durations are scaled down by how many calls overlapped,
+// keeping them within the wall-clock time taken. Once a call fails no
+// further shards are started, and the error of the earliest failed shard is
+// returned.
+func (s *SitemapOptions) forEachShard(n int, fn func(sub *SitemapOptions, i int) error) error {
+    subs := make([]SitemapOptions, n)
+    errs := make([]error, n)
+    busy := make([]time.Duration, n)
+    var next atomic.Int64
+    var failed atomic.Bool
+
+    start := time.Now()
+    var wg sync.WaitGroup
+    for w := 0; w < min(s.concurrency(), n); w++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for !failed.Load() {
+                i := int(next.Add(1) - 1)
+                if i >= n {
+                    return
+                }
+                subs[i] = *s
+                subs[i].resetRun()
+                started := time.Now()
+                if errs[i] = fn(&subs[i], i); errs[i] != nil {
+                    failed.Store(true)
+                }
+                busy[i] = time.Since(started)
+            }
+        }()
+    }
+    wg.Wait()
+    elapsed := time.Since(start)
+
+    var merged Stats
+    var total time.Duration
+    for i := range subs {
+        // Shards are started in order, so any shard never started comes
+        // after a failed one
+        if errs[i] != nil {
+            return errs[i]
+        }
+        s.files = append(s.files, subs[i].files...)
+        merged.add(subs[i].stats)
+        total += busy[i]
+        s.warnings = append(s.warnings, subs[i].warnings...)
+    }
+    if total > elapsed {
+        merged.scaleDurations(float64(elapsed) / float64(total))
+    }
+    s.stats.add(merged)
+    return nil
+}
@@ -0,0 +1,63 @@
+package nyxsitemap
+
+import (
+    "fmt"
+    "reflect"
+    "sync/atomic"
+    "testing"
+)
+
+func TestConcurrentShards(t *testing.T) {
+    counter := countValidations(t)
+    newOptions := func(concurrency int) *SitemapOptions {
+        sm := NewSitemapOptions("./test_sitemaps_concurrent", "https://www.example.com")
+        sm.MaxURLs = 1
+        sm.Concurrency = concurrency
+        for i := 1; i <= 20; i++ {
+            sm.AddURL(SitemapURL{Loc: fmt.Sprintf("/page/%d", i), LastMod: "2023-10-25"})
+        }
+        return sm
+    }
+
+    sequential := newOptions(1)
+    expected, err := sequential.WriteToMemory("https://www.example.com/")
+    if err != nil {
+        t.Fatalf("Error writing shards sequentially: %v", err)
+    }
+    concurrent := newOptions(8)
+    files, err := concurrent.WriteToMemory("https://www.example.com/")
+    if err != nil {
+        t.Fatalf("Error writing shards concurrently: %v", err)
+    }
+    if !reflect.DeepEqual(files, expected) {
+        t.Fatalf("Expected the same files whatever the concurrency")
+    }
+    if !reflect.DeepEqual(concurrent.Result().Filenames, sequential.Result().Filenames) {
+        t.Fatalf("Expected files in shard order, got %v", concurrent.Result().Filenames)
+    }
+
+    // The first invalid shard stops validation
+    corrupt := map[string]bool{}
+    for i := 1; i <= 20; i++ {
+        corrupt[shardName(i)] = true
+    }
+    sm := newOptions(1)
+    sm.FS = &corruptFS{MemFS: NewMemFS(), corrupt: corrupt}
+    before := atomic.LoadInt64(counter)
+    if err := sm.Write("https://www.example.com/"); err == nil {
+        t.Fatalf("Expected the invalid shards to fail Write")
+    }
+    if validations := atomic.LoadInt64(counter) - before; validations != 2 {
+        t.Fatalf("Expected validation to stop after the index and the first shard, got %d validations", validations)
+    }
+
+    sm = newOptions(4)
+    sm.FS = &corruptFS{MemFS: NewMemFS(), corrupt: corrupt}
+    before = atomic.LoadInt64(counter)
+    if err := sm.Write("https://www.example.com/"); err == nil {
+        t.Fatalf("Expected the invalid shards to fail a concurrent Write")
+    }
+    if validations := atomic.LoadInt64(counter) - before; validations > 1+4 {
+        t.Fatalf("Expected remaining shards to be skipped once one failed, got %d validations", validations)
+    }
+}
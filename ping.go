@@ -0,0 +1,172 @@
+package nyxsitemap
+
+import (
+    "context"
+    "fmt"
+    "net/http"
+    "net/url"
+    "runtime/debug"
+    "strings"
+    "sync"
+)
+
+// PingEndpoints maps engine names accepted by Ping to their ping endpoints,
+// to which the URL-encoded sitemap URL is appended. It is empty: Google and
+// Bing retired their sitemap ping endpoints, so callers register the
+// endpoints they rely on, or pass their URLs to Ping.
+var PingEndpoints = map[string]string{}
+
+// defaultUserAgent is sent when UserAgent is empty.
+var defaultUserAgent = "nyxsitemap/" + moduleVersion()
+
+// modulePath is the import path of this module.
+const modulePath = "github.com/soulkyn-ai/nyxsitemap"
+
+// moduleVersion returns the version of this module the binary was built
+// with, or "devel" when the build info doesn't tell, e.g. in its own tests
+// or with a replace directive pointing at a local copy.
+func moduleVersion() string {
+    info, ok := debug.ReadBuildInfo()
+    if !ok {
+        return "devel"
+    }
+    for _, dep := range info.Deps {
+        if dep.Path == modulePath && dep.Replace == nil && dep.Version != "" {
+            return dep.Version
+        }
+    }
+    if info.Main.Path == modulePath && info.Main.Version != "" && info.Main.Version != "(devel)" {
+        return info.Main.Version
+    }
+    return "devel"
+}
+
+// PingResult is the outcome of pinging one engine.
+type PingResult struct {
+    Engine     string // As passed to Ping
+    URL        string // The ping request URL
+    StatusCode int    // Zero when the request failed
+    Err        error  // Transport error or non-2xx status, nil on success
+}
+
+// Ping notifies search engines of the sitemap written by the last run,
+// sending a GET request with its SubmissionURL to each engine's endpoint.
+// Engines are names from PingEndpoints, or endpoint URLs the encoded sitemap
+// URL is appended to; at least one must be given. The engines are pinged
+// concurrently, within HTTPLimiter when set. One engine failing doesn't
+// stop the others: failures are reported in its PingResult, and the
+// returned error is only set when nothing could be pinged, e.g. before
+// Write, without engines or for an unknown engine name.
+func (s *SitemapOptions) Ping(ctx context.Context, engines ...string) ([]PingResult, error) {
+    sitemapURL, err := s.SubmissionURL()
+    if err != nil {
+        return nil, err
+    }
+    if len(engines) == 0 {
+        return nil, fmt.Errorf("no ping engines given")
+    }
+
+    endpoints := make([]string, len(engines))
+    for i, engine := range engines {
+        endpoint, ok := PingEndpoints[engine]
+        if !ok {
+            if u, err := url.Parse(engine); err != nil || !u.IsAbs() {
+                return nil, fmt.Errorf("unknown ping engine '%s'", engine)
+            }
+            endpoint = engine
+        }
+        endpoints[i] = endpoint
+    }
+
+    // The engines are pinged concurrently, within HTTPLimiter
+    results := make([]PingResult, len(engines))
+    var wg sync.WaitGroup
+    for i, engine := range engines {
+        wg.Add(1)
+        go func(i int, engine string) {
+            defer wg.Done()
+            results[i] = s.ping(ctx, engine, endpoints[i]+url.QueryEscape(sitemapURL))
+        }(i, engine)
+    }
+    wg.Wait()
+    return results, nil
+}
+
+// Limiter bounds how many HTTP requests are in flight at once. One limiter
+// can be shared by several SitemapOptions, bounding their requests combined.
+type Limiter interface {
+    // Acquire blocks until a request may be sent, or ctx is done.
+    Acquire(ctx context.Context) error
+    // Release marks a request acquired for as done.
+    Release()
+}
+
+// semaphore is the Limiter returned by NewLimiter.
+type semaphore chan struct{}
+
+// NewLimiter returns a Limiter allowing at most n requests in flight.
+func NewLimiter(n int) Limiter {
+    if n < 1 {
+        n = 1
+    }
+    return make(semaphore, n)
+}
+
+func (sem semaphore) Acquire(ctx context.Context) error {
+    select {
+    case sem <- struct{}{}:
+        return nil
+    case <-ctx.Done():
+        return ctx.Err()
+    }
+}
+
+func (sem semaphore) Release() {
+    <-sem
+}
+
+// ping sends a single ping request to pingURL.
+func (s *SitemapOptions) ping(ctx context.Context, engine, pingURL string) PingResult {
+    result := PingResult{Engine: engine, URL: pingURL}
+    if s.HTTPLimiter != nil {
+        if err := s.HTTPLimiter.Acquire(ctx); err != nil {
+            result.Err = fmt.Errorf("failed to ping %s: %v", engine, err)
+            return result
+        }
+        defer s.HTTPLimiter.Release()
+    }
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, pingURL, nil)
+    if err != nil {
+        result.Err = fmt.Errorf("failed to create ping request for %s: %v", engine, err)
+        return result
+    }
+    req.Header.Set("User-Agent", s.userAgent())
+
+    resp, err := s.httpClient().Do(req)
+    if err != nil {
+        result.Err = fmt.Errorf("failed to ping %s: %v", engine, err)
+        return result
+    }
+    resp.Body.Close()
+    result.StatusCode = resp.StatusCode
+    if resp.StatusCode < 200 || resp.StatusCode > 299 {
+        result.Err = fmt.Errorf("ping %s returned %s", engine, strings.TrimSpace(resp.Status))
+    }
+    return result
+}
+
+// httpClient returns HTTPClient, or http.DefaultClient when it is nil.
+func (s *SitemapOptions) httpClient() *http.Client {
+    if s.HTTPClient == nil {
+        return http.DefaultClient
+    }
+    return s.HTTPClient
+}
+
+// userAgent returns UserAgent, or the default when it is empty.
+func (s *SitemapOptions) userAgent() string {
+    if s.UserAgent == "" {
+        return defaultUserAgent
+    }
+    return s.UserAgent
+}
@@ -0,0 +1,161 @@
+package nyxsitemap
+
+import (
+    "context"
+    "net/http"
+    "net/http/httptest"
+    "sync"
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+func TestPing(t *testing.T) {
+    var mu sync.Mutex
+    var got []string
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        mu.Lock()
+        got = append(got, r.URL.Query().Get("sitemap"))
+        mu.Unlock()
+        if r.Header.Get("User-Agent") != "test-agent" {
+            t.Errorf("Unexpected User-Agent: %s", r.Header.Get("User-Agent"))
+        }
+        if r.URL.Path == "/down" {
+            w.WriteHeader(http.StatusServiceUnavailable)
+        }
+    }))
+    defer server.Close()
+
+    sm := NewSitemapOptions("./test_sitemaps_ping", "https://www.example.com")
+    sm.FS = NewMemFS()
+    sm.HTTPClient = server.Client()
+    sm.UserAgent = "test-agent"
+    sm.AddURL(SitemapURL{Loc: "/a", LastMod: "2023-10-25"})
+
+    if _, err := sm.Ping(context.Background(), server.URL+"/ping?sitemap="); err == nil {
+        t.Fatalf("Expected Ping to fail before Write")
+    }
+    if err := sm.Write(""); err != nil {
+        t.Fatalf("Error writing sitemap: %v", err)
+    }
+    if _, err := sm.Ping(context.Background()); err == nil {
+        t.Fatalf("Expected Ping to require an engine")
+    }
+    if _, err := sm.Ping(context.Background(), "google"); err == nil {
+        t.Fatalf("Expected Ping to reject an unregistered engine")
+    }
+
+    // Registered names resolve to their endpoints
+    PingEndpoints["test"] = server.URL + "/ping?sitemap="
+    defer delete(PingEndpoints, "test")
+    results, err := sm.Ping(context.Background(), "test")
+    if err != nil || results[0].Err != nil || results[0].Engine != "test" {
+        t.Fatalf("Expected the registered engine to be pinged, got: %+v, %v", results, err)
+    }
+    got = nil
+
+    // An unreachable engine doesn't fail the others
+    results, err = sm.Ping(context.Background(),
+        server.URL+"/ping?sitemap=", server.URL+"/down?sitemap=", "http://127.0.0.1:1/ping?sitemap=")
+    if err != nil {
+        t.Fatalf("Error pinging: %v", err)
+    }
+    if len(results) != 3 {
+        t.Fatalf("Expected 3 results, got %d", len(results))
+    }
+    if results[0].StatusCode != http.StatusOK || results[0].Err != nil {
+        t.Fatalf("Expected the first ping to succeed, got: %+v", results[0])
+    }
+    if results[1].StatusCode != http.StatusServiceUnavailable || results[1].Err == nil {
+        t.Fatalf("Expected the second ping to report a 503, got: %+v", results[1])
+    }
+    if results[2].StatusCode != 0 || results[2].Err == nil {
+        t.Fatalf("Expected the third ping to fail, got: %+v", results[2])
+    }
+    if len(got) != 2 || got[0] != "https://www.example.com/sitemap.xml" {
+        t.Fatalf("Unexpected sitemap URLs pinged: %v", got)
+    }
+
+    // A cancelled context stops the request
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+    results, err = sm.Ping(ctx, server.URL+"/ping?sitemap=")
+    if err != nil || results[0].Err == nil {
+        t.Fatalf("Expected the ping to fail with a cancelled context, got: %+v, %v", results, err)
+    }
+}
+
+func TestDefaultUserAgent(t *testing.T) {
+    var got string
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        got = r.Header.Get("User-Agent")
+    }))
+    defer server.Close()
+
+    sm := NewSitemapOptions("./test_sitemaps_user_agent", "https://www.example.com")
+    sm.FS = NewMemFS()
+    sm.HTTPClient = server.Client()
+    sm.AddURL(SitemapURL{Loc: "/a", LastMod: "2023-10-25"})
+    if err := sm.Write(""); err != nil {
+        t.Fatalf("Error writing sitemap: %v", err)
+    }
+    if _, err := sm.Ping(context.Background(), server.URL+"/ping?sitemap="); err != nil {
+        t.Fatalf("Error pinging: %v", err)
+    }
+    // The module's own tests have no version in their build info
+    if got != "nyxsitemap/devel" {
+        t.Fatalf("Expected the default User-Agent to carry the version, got %s", got)
+    }
+}
+
+func TestPingSharedLimiter(t *testing.T) {
+    var inFlight, peak int64
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        n := atomic.AddInt64(&inFlight, 1)
+        for {
+            max := atomic.LoadInt64(&peak)
+            if n <= max || atomic.CompareAndSwapInt64(&peak, max, n) {
+                break
+            }
+        }
+        time.Sleep(10 * time.Millisecond)
+        atomic.AddInt64(&inFlight, -1)
+    }))
+    defer server.Close()
+
+    // Two sitemaps pinging at once share a budget of 2 requests
+    limiter := NewLimiter(2)
+    engines := make([]string, 5)
+    for i := range engines {
+        engines[i] = server.URL + "/ping?sitemap="
+    }
+    var wg sync.WaitGroup
+    for i := 0; i < 2; i++ {
+        sm := NewSitemapOptions("./test_sitemaps_ping_limiter", "https://www.example.com")
+        sm.FS = NewMemFS()
+        sm.HTTPClient = server.Client()
+        sm.HTTPLimiter = limiter
+        sm.AddURL(SitemapURL{Loc: "/a", LastMod: "2023-10-25"})
+        if err := sm.Write(""); err != nil {
+            t.Fatalf("Error writing sitemap: %v", err)
+        }
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            results, err := sm.Ping(context.Background(), engines...)
+            if err != nil {
+                t.Errorf("Error pinging: %v", err)
+                return
+            }
+            for _, result := range results {
+                if result.Err != nil {
+                    t.Errorf("Unexpected ping failure: %+v", result)
+                }
+            }
+        }()
+    }
+    wg.Wait()
+    if peak < 1 || peak > 2 {
+        t.Fatalf("Expected at most 2 concurrent requests combined, got %d", peak)
+    }
+}
@@ -0,0 +1,170 @@
+package nyxsitemap
+
+import (
+    "encoding/xml"
+    "fmt"
+    "io"
+    "net/url"
+    "os"
+    "path/filepath"
+    "strings"
+)
+
+// ParseSitemap parses a sitemap from r, gzipped or not.
+func ParseSitemap(r io.Reader) (*URLSet, error) {
+    data, err := readDocument(r)
+    if err != nil {
+        return nil, err
+    }
+    var urlSet URLSet
+    if err := xml.Unmarshal(data, &urlSet); err != nil {
+        return nil, fmt.Errorf("XML unmarshalling failed for sitemap: %v", err)
+    }
+    return &urlSet, nil
+}
+
+// ParseSitemapIndex parses a sitemap index from r, gzipped or not.
+func ParseSitemapIndex(r io.Reader) (*SitemapIndex, error) {
+    data, err := readDocument(r)
+    if err != nil {
+        return nil, err
+    }
+    var index SitemapIndex
+    if err := xml.Unmarshal(data, &index); err != nil {
+        return nil, fmt.Errorf("XML unmarshalling failed for sitemap index: %v", err)
+    }
+    return &index, nil
+}
+
+// readDocument reads r whole, decompressing it when gzipped.
+func readDocument(r io.Reader) ([]byte, error) {
+    raw, err := io.ReadAll(r)
+    if err != nil {
+        return nil, err
+    }
+    data, err := gunzipIfNeeded(raw)
+    if err != nil {
+        return nil, fmt.Errorf("failed to decompress sitemap: %v", err)
+    }
+    return data, nil
+}
+
+// ReadIndexURLs reads the sitemap index at indexPath and returns the URLs of
+// all the sitemap files it references, in index order. Each sitemap file is
+// looked up in the index's directory by the end of its loc's path, keeping
+// subdirectories such as those of the ByYear layout. Gzipped files are
+// supported.
+func ReadIndexURLs(indexPath string) ([]SitemapURL, error) {
+    raw, err := os.ReadFile(indexPath)
+    if err != nil {
+        return nil, err
+    }
+    data, err := gunzipIfNeeded(raw)
+    if err != nil {
+        return nil, fmt.Errorf("failed to decompress sitemap index '%s': %v", indexPath, err)
+    }
+    var index SitemapIndex
+    if err := xml.Unmarshal(data, &index); err != nil {
+        return nil, fmt.Errorf("XML unmarshalling failed for sitemap index '%s': %v", indexPath, err)
+    }
+
+    var urls []SitemapURL
+    for _, sitemap := range index.Sitemaps {
+        sitemapURL, err := url.Parse(sitemap.Loc)
+        if err != nil {
+            return nil, fmt.Errorf("invalid sitemap URL '%s': %v", sitemap.Loc, err)
+        }
+        filePath, err := shardFilePath(filepath.Dir(indexPath), sitemapURL.Path)
+        if err != nil {
+            return nil, err
+        }
+        // A self-referencing entry is the index being read
+        if filepath.Clean(filePath) == filepath.Clean(indexPath) {
+            continue
+        }
+        _, urlSet, err := readURLSet(filePath)
+        if err != nil {
+            return nil, err
+        }
+        urls = append(urls, urlSet.URLs...)
+    }
+    return urls, nil
+}
+
+// shardFilePath returns the file in dir for a sitemap served at urlPath: the
+// longest trailing part of urlPath that exists in dir, or its filename. A
+// path with .. segments is rejected, as it could lead out of dir.
+func shardFilePath(dir, urlPath string) (string, error) {
+    segments := strings.Split(strings.Trim(urlPath, "/"), "/")
+    for _, segment := range segments {
+        if segment == ".." || strings.Contains(segment, "\\") {
+            return "", fmt.Errorf("sitemap path '%s' must not leave '%s'", urlPath, dir)
+        }
+    }
+    for i := range segments {
+        filePath := filepath.Join(append([]string{dir}, segments[i:]...)...)
+        if info, err := os.Stat(filePath); err == nil && !info.IsDir() {
+            return filePath, nil
+        }
+    }
+    return filepath.Join(dir, segments[len(segments)-1]), nil
+}
+
+// LoadDir loads the URLs of the sitemaps generated in dir by a previous run,
+// from its sitemap index, or from its single sitemap when there is no index,
+// gzipped or not, so they can be updated and written again. The returned
+// options have the defaults of NewSitemapOptions and no BaseURL; locs are
+// the absolute URLs read, see TrimBaseURL.
+func LoadDir(dir string) (*SitemapOptions, error) {
+    var urls []SitemapURL
+    if indexPath, ok := findFile(dir, "sitemap_index.xml"); ok {
+        indexURLs, err := ReadIndexURLs(indexPath)
+        if err != nil {
+            return nil, err
+        }
+        urls = indexURLs
+    } else if sitemapPath, ok := findFile(dir, "sitemap.xml"); ok {
+        _, urlSet, err := readURLSet(sitemapPath)
+        if err != nil {
+            return nil, err
+        }
+        urls = urlSet.URLs
+    } else {
+        return nil, fmt.Errorf("no sitemap_index.xml or sitemap.xml found in '%s'", dir)
+    }
+
+    s := NewSitemapOptions(dir, "")
+    s.URLs = append(s.URLs, urls...)
+    return s, nil
+}
+
+// findFile returns the path of name in dir, or of its gzipped version.
+func findFile(dir, name string) (string, bool) {
+    for _, candidate := range []string{name, name + ".gz"} {
+        filePath := filepath.Join(dir, candidate)
+        if _, err := os.Stat(filePath); err == nil {
+            return filePath, true
+        }
+    }
+    return "", false
+}
+
+// TrimBaseURL turns the locs of URLs under BaseURL back into paths relative
+// to it, e.g. after LoadDir and setting BaseURL, so that Write resolves them
+// against whatever BaseURL is set next. Other locs are left untouched.
+func (s *SitemapOptions) TrimBaseURL() {
+    base := strings.TrimRight(s.BaseURL, "/")
+    if base == "" {
+        return
+    }
+    for i, u := range s.URLs {
+        rest, ok := strings.CutPrefix(u.Loc, base)
+        if !ok || (rest != "" && !strings.HasPrefix(rest, "/") && !strings.HasPrefix(rest, "?")) {
+            continue
+        }
+        if !strings.HasPrefix(rest, "/") {
+            rest = "/" + rest
+        }
+        s.URLs[i].Loc = rest
+    }
+}
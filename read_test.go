@@ -0,0 +1,138 @@
+package nyxsitemap
+
+import (
+    "bytes"
+    "os"
+    "path"
+    "strings"
+    "testing"
+)
+
+func TestReadIndexURLs(t *testing.T) {
+    dir := "./test_sitemaps_read_index"
+    os.RemoveAll(dir)
+    defer os.RemoveAll(dir)
+
+    sm := NewSitemapOptions(dir, "https://www.example.com")
+    sm.MaxURLs = 2
+    sm.SelfReferenceIndex = true
+    sm.AddURL(SitemapURL{Loc: "/a", LastMod: "2023-10-25"})
+    sm.AddURL(SitemapURL{Loc: "/b", LastMod: "2023-10-25"})
+    sm.AddURL(SitemapURL{Loc: "/c", LastMod: "2023-10-25"})
+    if err := sm.Write("https://www.example.com/sitemaps/"); err != nil {
+        t.Fatalf("Error writing sitemaps: %v", err)
+    }
+
+    // Gzipped shards are read too
+    shard := path.Join(dir, "sitemap_2.xml")
+    data, _ := os.ReadFile(shard)
+    gzipped, _ := gzipBytes(data)
+    os.WriteFile(shard, gzipped, 0644)
+
+    urls, err := ReadIndexURLs(path.Join(dir, "sitemap_index.xml"))
+    if err != nil {
+        t.Fatalf("Error reading index URLs: %v", err)
+    }
+    expected := []string{"https://www.example.com/a", "https://www.example.com/b", "https://www.example.com/c"}
+    if len(urls) != len(expected) {
+        t.Fatalf("Expected %d URLs, got %d", len(expected), len(urls))
+    }
+    for i, loc := range expected {
+        if urls[i].Loc != loc || urls[i].LastMod != "2023-10-25" {
+            t.Fatalf("Unexpected URL %d: %+v", i, urls[i])
+        }
+    }
+}
+
+func TestReadIndexURLsRejectsEscapingPaths(t *testing.T) {
+    dir := "./test_sitemaps_read_escape"
+    os.RemoveAll(dir)
+    defer os.RemoveAll(dir)
+    os.MkdirAll(dir, 0755)
+
+    for _, loc := range []string{
+        "https://www.example.com/sitemaps/../../secret.xml",
+        "https://www.example.com/sitemaps/%2e%2e/secret.xml",
+    } {
+        index := `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"><sitemap><loc>` + loc + `</loc></sitemap></sitemapindex>`
+        indexPath := path.Join(dir, "sitemap_index.xml")
+        os.WriteFile(indexPath, []byte(index), 0644)
+        if _, err := ReadIndexURLs(indexPath); err == nil || !strings.Contains(err.Error(), "must not leave") {
+            t.Fatalf("Expected '%s' to be rejected, got %v", loc, err)
+        }
+    }
+}
+
+func TestParseSitemap(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_parse", "https://www.example.com")
+    sm.MaxURLs = 1
+    sm.GzipIndex = true
+    sm.AddURL(SitemapURL{Loc: "/a", LastMod: "2023-10-25", Priority: "0.8"})
+    sm.AddURL(SitemapURL{Loc: "/b", LastMod: "2023-10-25"})
+    files, err := sm.WriteToMemory("https://www.example.com/")
+    if err != nil {
+        t.Fatalf("Error writing sitemaps: %v", err)
+    }
+
+    urlSet, err := ParseSitemap(bytes.NewReader(files["sitemap_1.xml"]))
+    if err != nil {
+        t.Fatalf("Error parsing sitemap: %v", err)
+    }
+    if len(urlSet.URLs) != 1 || urlSet.URLs[0].Loc != "https://www.example.com/a" || urlSet.URLs[0].Priority != "0.8" {
+        t.Fatalf("Unexpected parsed URLs: %+v", urlSet.URLs)
+    }
+    index, err := ParseSitemapIndex(bytes.NewReader(files["sitemap_index.xml.gz"]))
+    if err != nil {
+        t.Fatalf("Error parsing gzipped sitemap index: %v", err)
+    }
+    if len(index.Sitemaps) != 2 || index.Sitemaps[1].Loc != "https://www.example.com/sitemap_2.xml" {
+        t.Fatalf("Unexpected parsed index: %+v", index.Sitemaps)
+    }
+    if _, err := ParseSitemap(bytes.NewReader(files["sitemap_index.xml.gz"])); err == nil {
+        t.Fatalf("Expected an index not to parse as a sitemap")
+    }
+}
+
+func TestLoadDir(t *testing.T) {
+    dir := "./test_sitemaps_load_dir"
+    os.RemoveAll(dir)
+    defer os.RemoveAll(dir)
+
+    if _, err := LoadDir(dir); err == nil {
+        t.Fatalf("Expected LoadDir to fail without sitemaps")
+    }
+
+    sm := NewSitemapOptions(dir, "https://www.example.com")
+    sm.DirLayout = ByYear
+    sm.Gzip = true
+    sm.AddURL(SitemapURL{Loc: "/a", LastMod: "2022-05-01"})
+    sm.AddURL(SitemapURL{Loc: "/b?page=2", LastMod: "2023-05-01"})
+    sm.AddURL(SitemapURL{Loc: "https://cdn.example.com/c", LastMod: "2023-05-01"})
+    if err := sm.Write("https://www.example.com/sitemaps/"); err != nil {
+        t.Fatalf("Error writing sitemaps: %v", err)
+    }
+
+    loaded, err := LoadDir(dir)
+    if err != nil {
+        t.Fatalf("Error loading sitemaps: %v", err)
+    }
+    expected := []string{"https://www.example.com/a", "https://www.example.com/b?page=2", "https://cdn.example.com/c"}
+    if len(loaded.URLs) != len(expected) {
+        t.Fatalf("Expected %d URLs, got %d", len(expected), len(loaded.URLs))
+    }
+    for i, loc := range expected {
+        if loaded.URLs[i].Loc != loc {
+            t.Fatalf("Expected URL %d to be %s, got %s", i, loc, loaded.URLs[i].Loc)
+        }
+    }
+
+    loaded.BaseURL = "https://www.example.com"
+    loaded.TrimBaseURL()
+    expected = []string{"/a", "/b?page=2", "https://cdn.example.com/c"}
+    for i, loc := range expected {
+        if loaded.URLs[i].Loc != loc {
+            t.Fatalf("Expected URL %d to be trimmed to %s, got %s", i, loc, loaded.URLs[i].Loc)
+        }
+    }
+}
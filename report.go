@@ -0,0 +1,98 @@
+package nyxsitemap
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "path"
+)
+
+// report is the JSON document written by WriteReport.
+type report struct {
+    URLs     int            `json:"urls"`    // URLs written
+    Omitted  int            `json:"omitted"` // URLs left out to honor MaxTotalBytes
+    Files    []reportFile   `json:"files"`
+    Warnings []string       `json:"warnings"`
+    Changes  []reportChange `json:"changes"`
+    Timings  reportTimings  `json:"timings"`
+    Counts   reportCounts   `json:"counts"`
+}
+
+type reportFile struct {
+    Name     string `json:"name"`
+    Size     int    `json:"size"`
+    URLCount int    `json:"url_count"`
+    SHA256   string `json:"sha256"`
+}
+
+type reportChange struct {
+    Loc      string `json:"loc"`
+    Field    string `json:"field"`
+    Original string `json:"original"`
+    Final    string `json:"final"`
+    Reason   string `json:"reason"`
+}
+
+// reportTimings holds the Stats durations in nanoseconds.
+type reportTimings struct {
+    Marshal  int64 `json:"marshal_ns"`
+    Validate int64 `json:"validate_ns"`
+    Write    int64 `json:"write_ns"`
+    Total    int64 `json:"total_ns"`
+}
+
+type reportCounts struct {
+    Images     int `json:"images"`
+    Videos     int `json:"videos"`
+    Alternates int `json:"alternates"`
+}
+
+// WriteReport writes a JSON summary of the last run to filePath on the
+// FileSystem, for CI pipelines to archive and diff: the URL counts, every
+// output file with its size and SHA-256, Warnings, Changes and Stats.
+func (s *SitemapOptions) WriteReport(filePath string) error {
+    r := report{
+        Omitted:  s.omitted,
+        Files:    []reportFile{},
+        Warnings: append([]string{}, s.warnings...),
+        Changes:  []reportChange{},
+        Timings: reportTimings{
+            Marshal:  int64(s.stats.MarshalDuration),
+            Validate: int64(s.stats.ValidateDuration),
+            Write:    int64(s.stats.WriteDuration),
+            Total:    int64(s.stats.TotalDuration),
+        },
+        Counts: reportCounts{
+            Images:     s.stats.Images,
+            Videos:     s.stats.Videos,
+            Alternates: s.stats.Alternates,
+        },
+    }
+    for _, file := range s.files {
+        data, err := s.fs().ReadFile(path.Join(s.Dir, file.Name))
+        if err != nil {
+            return fmt.Errorf("failed to read '%s' for the report: %v", file.Name, err)
+        }
+        sum := sha256.Sum256(data)
+        r.URLs += file.URLCount
+        r.Files = append(r.Files, reportFile{
+            Name:     file.Name,
+            Size:     file.Size,
+            URLCount: file.URLCount,
+            SHA256:   hex.EncodeToString(sum[:]),
+        })
+    }
+    for _, change := range s.changes {
+        r.Changes = append(r.Changes, reportChange(change))
+    }
+
+    data, err := json.MarshalIndent(r, "", "  ")
+    if err != nil {
+        return err
+    }
+    if err := s.fs().MkdirAll(path.Dir(filePath), 0755); err != nil {
+        return err
+    }
+    return s.fs().WriteFile(filePath, append(data, '\n'), 0644)
+}
@@ -0,0 +1,55 @@
+package nyxsitemap
+
+import (
+    "encoding/json"
+    "testing"
+)
+
+func TestWriteReport(t *testing.T) {
+    fs := NewMemFS()
+    sm := NewSitemapOptions("./test_sitemaps_report", "https://www.example.com")
+    sm.FS = fs
+    sm.RecordChanges = true
+    sm.AddURL(SitemapURL{Loc: "/", LastMod: "2023-10-25"})
+    sm.AddURL(SitemapURL{Loc: "/archive", ChangeFreq: "never", LastMod: "2023-10-25"})
+    if err := sm.Write(""); err != nil {
+        t.Fatalf("Error writing sitemap: %v", err)
+    }
+    if err := sm.WriteReport("reports/report.json"); err != nil {
+        t.Fatalf("Error writing report: %v", err)
+    }
+
+    data, err := fs.ReadFile("reports/report.json")
+    if err != nil {
+        t.Fatalf("Error reading report: %v", err)
+    }
+    var r report
+    if err := json.Unmarshal(data, &r); err != nil {
+        t.Fatalf("Error parsing report: %v", err)
+    }
+    if r.URLs != 2 {
+        t.Fatalf("Expected 2 URLs in the report, got %d", r.URLs)
+    }
+    if len(r.Files) != len(sm.Files()) || r.Files[len(r.Files)-1].Name != "sitemap.xml" {
+        t.Fatalf("Expected the report to list the written files, got %+v", r.Files)
+    }
+    if len(r.Files[len(r.Files)-1].SHA256) != 64 {
+        t.Fatalf("Expected a SHA-256 for every file, got %+v", r.Files)
+    }
+    if len(r.Changes) != 2 || r.Changes[0].Field != "loc" {
+        t.Fatalf("Expected the resolved locs in the report changes, got %+v", r.Changes)
+    }
+    if r.Timings.Total <= 0 {
+        t.Fatalf("Expected the run timings in the report, got %+v", r.Timings)
+    }
+
+    var sections map[string]json.RawMessage
+    if err := json.Unmarshal(data, &sections); err != nil {
+        t.Fatalf("Error parsing report: %v", err)
+    }
+    for _, section := range []string{"urls", "files", "warnings", "changes", "timings", "counts"} {
+        if _, ok := sections[section]; !ok {
+            t.Fatalf("Expected a %q section in the report:\n%s", section, data)
+        }
+    }
+}
@@ -0,0 +1,73 @@
+package nyxsitemap
+
+import (
+    "encoding/xml"
+    "fmt"
+    "path"
+    "time"
+)
+
+// RewriteShard regenerates only the n-th (1-based) shard Write would produce
+// from the current URLs, and refreshes its entry in the existing
+// sitemap_index.xml. The other shards and index entries are left untouched,
+// so URLs should only have changed within that shard. Dirs get copies of
+// the two rewritten files.
+func (s *SitemapOptions) RewriteShard(n int, baseSitemapURL string) error {
+    s.resetRun()
+    defer s.endRun(time.Now())
+
+    if err := s.prepareURLs(); err != nil {
+        return err
+    }
+    if !s.needsIndex() {
+        return fmt.Errorf("URLs fit in a single sitemap, there is no shard %d to rewrite", n)
+    }
+    shards := s.shards()
+    if n < 1 || n > len(shards) {
+        return fmt.Errorf("shard %d out of range, URLs make %d shards", n, len(shards))
+    }
+    shard := shards[n-1]
+
+    indexPath := path.Join(s.Dir, s.indexName())
+    raw, err := s.fs().ReadFile(indexPath)
+    if err != nil {
+        return fmt.Errorf("failed to read sitemap index: %v", err)
+    }
+    indexData, err := gunzipIfNeeded(raw)
+    if err != nil {
+        return fmt.Errorf("failed to decompress sitemap index: %v", err)
+    }
+    var index SitemapIndex
+    if err := xml.Unmarshal(indexData, &index); err != nil {
+        return fmt.Errorf("XML unmarshalling failed for sitemap index: %v", err)
+    }
+
+    sitemapURL, err := s.resolveSitemapURL(baseSitemapURL, shard.name)
+    if err != nil {
+        return err
+    }
+    found := false
+    for i := range index.Sitemaps {
+        if index.Sitemaps[i].Loc == sitemapURL {
+            index.Sitemaps[i].LastMod = s.indexLastMod(shard.urls)
+            found = true
+        }
+    }
+    if !found {
+        return fmt.Errorf("sitemap index has no entry for '%s'", sitemapURL)
+    }
+
+    if err := s.writeSitemapFile(shard.name, shard.urls); err != nil {
+        return err
+    }
+    if err := s.validateXMLFile(path.Join(s.Dir, shard.name), false); err != nil {
+        return err
+    }
+    if err := s.writeIndexDocument(index); err != nil {
+        return err
+    }
+    if err := s.validateXMLFile(indexPath, true); err != nil {
+        return err
+    }
+    return s.mirrorFiles()
+}
@@ -0,0 +1,56 @@
+package nyxsitemap
+
+import (
+    "bytes"
+    "strings"
+    "testing"
+)
+
+func TestRewriteShard(t *testing.T) {
+    memFS := NewMemFS()
+    baseSitemapURL := "https://www.example.com/sitemaps/"
+    sm := NewSitemapOptions("test_sitemaps_rewrite", "https://www.example.com")
+    sm.FS = memFS
+    sm.MaxURLs = 2
+    sm.Canonical = true
+    sm.AddURLs([]SitemapURL{
+        {Loc: "/a", LastMod: "2023-01-01"},
+        {Loc: "/b", LastMod: "2023-01-01"},
+        {Loc: "/c", LastMod: "2023-01-01"},
+        {Loc: "/d", LastMod: "2023-01-01"},
+        {Loc: "/e", LastMod: "2023-01-01"},
+    })
+    if err := sm.Write(baseSitemapURL); err != nil {
+        t.Fatalf("Error writing sitemaps: %v", err)
+    }
+
+    before := map[string][]byte{}
+    for _, name := range memFS.Names() {
+        before[name], _ = memFS.ReadFile(name)
+    }
+
+    // Shard 2 holds /c and /d
+    sm.URLs[2].LastMod = "2023-06-01"
+    if err := sm.RewriteShard(2, baseSitemapURL); err != nil {
+        t.Fatalf("Error rewriting shard: %v", err)
+    }
+
+    changed := map[string]bool{}
+    for _, name := range memFS.Names() {
+        data, _ := memFS.ReadFile(name)
+        if !bytes.Equal(data, before[name]) {
+            changed[name] = true
+        }
+    }
+    if len(changed) != 2 || !changed["test_sitemaps_rewrite/sitemap_2.xml"] || !changed["test_sitemaps_rewrite/sitemap_index.xml"] {
+        t.Fatalf("Expected only shard 2 and the index to change, got %v", changed)
+    }
+    index, _ := memFS.ReadFile("test_sitemaps_rewrite/sitemap_index.xml")
+    if strings.Count(string(index), "<lastmod>2023-06-01</lastmod>") != 1 {
+        t.Fatalf("Expected only shard 2's index lastmod to be refreshed, got:\n%s", index)
+    }
+
+    if err := sm.RewriteShard(4, baseSitemapURL); err == nil {
+        t.Fatalf("Expected an error for a shard out of range")
+    }
+}
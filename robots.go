@@ -0,0 +1,62 @@
+package nyxsitemap
+
+import (
+    "errors"
+    "fmt"
+    "net/url"
+    "path"
+    "strings"
+)
+
+// recordTopURL records the absolute URL of the file to submit for the run
+// that just completed: the index, or the single sitemap. Without a
+// baseSitemapURL, a single sitemap is taken to be served from BaseURL.
+func (s *SitemapOptions) recordTopURL(baseSitemapURL string) error {
+    name := s.singleName()
+    if s.index != nil {
+        name = s.indexName()
+    }
+    if baseSitemapURL == "" {
+        baseSitemapURL = s.BaseURL
+    }
+    topURL, err := s.resolveSitemapURL(baseSitemapURL, name)
+    if err != nil {
+        return err
+    }
+    s.topURL = topURL
+    return nil
+}
+
+// SubmissionURL returns the absolute URL of the sitemap index written by the
+// last run, or of its single sitemap when no index was needed: the URL to
+// submit to search engines.
+func (s *SitemapOptions) SubmissionURL() (string, error) {
+    if s.topURL == "" {
+        return "", errors.New("no sitemap was written yet, call Write first")
+    }
+    if u, err := url.Parse(s.topURL); err != nil || !u.IsAbs() {
+        return "", fmt.Errorf("sitemap URL '%s' is not absolute, pass an absolute baseSitemapURL to Write", s.topURL)
+    }
+    return s.topURL, nil
+}
+
+// WriteRobotsTxt writes a robots.txt to filePath on the FileSystem with a
+// Sitemap: line for the last run's index or single sitemap, followed by
+// extraLines verbatim, e.g. "User-agent: *" and "Disallow: /admin".
+func (s *SitemapOptions) WriteRobotsTxt(filePath string, extraLines ...string) error {
+    sitemapURL, err := s.SubmissionURL()
+    if err != nil {
+        return err
+    }
+
+    var builder strings.Builder
+    builder.WriteString("Sitemap: " + sitemapURL + "\n")
+    for _, line := range extraLines {
+        builder.WriteString(line + "\n")
+    }
+
+    if err := s.fs().MkdirAll(path.Dir(filePath), 0755); err != nil {
+        return err
+    }
+    return s.fs().WriteFile(filePath, []byte(builder.String()), 0644)
+}
@@ -0,0 +1,40 @@
+package nyxsitemap
+
+import "testing"
+
+func TestWriteRobotsTxt(t *testing.T) {
+    fs := NewMemFS()
+    sm := NewSitemapOptions("./test_sitemaps_robots", "https://www.example.com")
+    sm.FS = fs
+    sm.MaxURLs = 1
+    sm.AddURL(SitemapURL{Loc: "/a", LastMod: "2023-10-25"})
+
+    if err := sm.WriteRobotsTxt("robots.txt"); err == nil {
+        t.Fatalf("Expected WriteRobotsTxt to fail before Write")
+    }
+
+    // A single sitemap without a baseSitemapURL is served from BaseURL
+    if err := sm.Write(""); err != nil {
+        t.Fatalf("Error writing sitemap: %v", err)
+    }
+    if err := sm.WriteRobotsTxt("robots.txt", "User-agent: *", "Disallow: /admin"); err != nil {
+        t.Fatalf("Error writing robots.txt: %v", err)
+    }
+    data, _ := fs.ReadFile("robots.txt")
+    expected := "Sitemap: https://www.example.com/sitemap.xml\nUser-agent: *\nDisallow: /admin\n"
+    if string(data) != expected {
+        t.Fatalf("Unexpected robots.txt for a single sitemap:\n%s", data)
+    }
+
+    sm.AddURL(SitemapURL{Loc: "/b", LastMod: "2023-10-25"})
+    if err := sm.Write("https://www.example.com/sitemaps/"); err != nil {
+        t.Fatalf("Error writing sitemaps: %v", err)
+    }
+    if err := sm.WriteRobotsTxt("robots.txt"); err != nil {
+        t.Fatalf("Error writing robots.txt: %v", err)
+    }
+    data, _ = fs.ReadFile("robots.txt")
+    if string(data) != "Sitemap: https://www.example.com/sitemaps/sitemap_index.xml\n" {
+        t.Fatalf("Unexpected robots.txt for an index:\n%s", data)
+    }
+}
@@ -0,0 +1,38 @@
+package nyxsitemap
+
+import (
+    "encoding/xml"
+    "fmt"
+    "path"
+)
+
+// verifyRoundTrip reads back the sitemap file written as filename and
+// checks that it parses into the given URLs, comparing their count and
+// their loc, lastmod, changefreq and priority.
+func (s *SitemapOptions) verifyRoundTrip(filename string, urls []SitemapURL) error {
+    raw, err := s.fs().ReadFile(path.Join(s.Dir, filename))
+    if err != nil {
+        return fmt.Errorf("failed to read '%s' for round-trip verification: %v", filename, err)
+    }
+    data, err := gunzipIfNeeded(raw)
+    if err != nil {
+        return fmt.Errorf("failed to decompress '%s' for round-trip verification: %v", filename, err)
+    }
+    var urlSet URLSet
+    if err := xml.Unmarshal(data, &urlSet); err != nil {
+        return fmt.Errorf("XML unmarshalling failed for sitemap '%s': %v", filename, err)
+    }
+
+    if len(urlSet.URLs) != len(urls) {
+        return fmt.Errorf("'%s' parses into %d URLs, %d were written", filename, len(urlSet.URLs), len(urls))
+    }
+    for i, u := range urls {
+        got := urlSet.URLs[i]
+        if got.Loc != u.Loc || got.LastMod != u.LastMod || got.ChangeFreq != u.ChangeFreq || got.Priority != u.Priority {
+            return fmt.Errorf("URL %d of '%s' parses as %s (%s, %s, %s), expected %s (%s, %s, %s)",
+                i+1, filename, got.Loc, got.LastMod, got.ChangeFreq, got.Priority,
+                u.Loc, u.LastMod, u.ChangeFreq, u.Priority)
+        }
+    }
+    return nil
+}
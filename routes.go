@@ -0,0 +1,49 @@
+package nyxsitemap
+
+import "strings"
+
+// AddRoutes adds a URL for every static route among patterns, e.g. the
+// patterns registered with an http.ServeMux, and returns how many were
+// added. Parameterized routes ("/posts/{id}", "/posts/:id", "/files/*")
+// are skipped, as are routes for methods other than GET and HEAD. A
+// "{$}" end anchor is dropped, and a path is added once even when several
+// patterns match it. Adding stops once MaxURLsInMemory is reached.
+func (s *SitemapOptions) AddRoutes(patterns []string) int {
+    seen := map[string]bool{}
+    added := 0
+    for _, pattern := range patterns {
+        route, ok := staticRoute(pattern)
+        if !ok || seen[route] {
+            continue
+        }
+        seen[route] = true
+        if err := s.AddURL(SitemapURL{Loc: route}); err != nil {
+            break
+        }
+        added++
+    }
+    return added
+}
+
+// staticRoute returns the path matched by a route pattern, and false when
+// the pattern is parameterized or not for a page.
+func staticRoute(pattern string) (string, bool) {
+    pattern = strings.TrimSpace(pattern)
+    if method, route, found := strings.Cut(pattern, " "); found {
+        if method != "GET" && method != "HEAD" {
+            return "", false
+        }
+        pattern = strings.TrimSpace(route)
+    }
+    pattern = strings.TrimSuffix(pattern, "{$}")
+    if !strings.HasPrefix(pattern, "/") {
+        // Host-qualified patterns are served for another BaseURL
+        return "", false
+    }
+    for _, segment := range strings.Split(pattern, "/") {
+        if strings.ContainsAny(segment, "{}*") || strings.HasPrefix(segment, ":") {
+            return "", false
+        }
+    }
+    return pattern, true
+}
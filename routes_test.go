@@ -0,0 +1,30 @@
+package nyxsitemap
+
+import "testing"
+
+func TestAddRoutes(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_routes", "https://www.example.com")
+    added := sm.AddRoutes([]string{
+        "/{$}",
+        "/about",
+        "GET /pricing",
+        "POST /pricing",
+        "HEAD /pricing",
+        "/posts/{id}",
+        "/users/:name",
+        "/static/*",
+        "GET /files/{path...}",
+        "api.example.com/status",
+        "/blog/",
+    })
+    if added != 4 {
+        t.Fatalf("Expected 4 static routes to be added, got %d", added)
+    }
+
+    expected := []string{"/", "/about", "/pricing", "/blog/"}
+    for i, loc := range expected {
+        if sm.URLs[i].Loc != loc {
+            t.Fatalf("Expected URL %d to be %s, got %s", i, loc, sm.URLs[i].Loc)
+        }
+    }
+}
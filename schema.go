@@ -0,0 +1,147 @@
+package nyxsitemap
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/xml"
+    "fmt"
+    "io"
+    "net/http"
+    "os"
+    "path/filepath"
+    "strings"
+)
+
+// readSchemaSource returns the local path and target namespace of a schema
+// source.
+func (s *SitemapOptions) readSchemaSource(source string) (string, string, error) {
+    filePath, err := s.localSchemaPath(source)
+    if err != nil {
+        return "", "", err
+    }
+    data, err := os.ReadFile(filePath)
+    if err != nil {
+        return "", "", fmt.Errorf("failed to read schema '%s': %v", source, err)
+    }
+    namespace, err := schemaTargetNamespace(data)
+    if err != nil {
+        return "", "", fmt.Errorf("failed to read target namespace of schema '%s': %v", source, err)
+    }
+    return filePath, namespace, nil
+}
+
+// localSchemaPath returns an absolute local path for the schema source,
+// downloading remote schemas into a cache directory on first use with
+// HTTPClient and UserAgent.
+func (s *SitemapOptions) localSchemaPath(source string) (string, error) {
+    if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+        return filepath.Abs(source)
+    }
+
+    sum := sha256.Sum256([]byte(source))
+    cacheDir := filepath.Join(os.TempDir(), "nyxsitemap-schemas")
+    filePath := filepath.Join(cacheDir, hex.EncodeToString(sum[:])+".xsd")
+    if _, err := os.Stat(filePath); err == nil {
+        return filePath, nil
+    }
+
+    req, err := http.NewRequest(http.MethodGet, source, nil)
+    if err != nil {
+        return "", fmt.Errorf("failed to fetch schema '%s': %v", source, err)
+    }
+    req.Header.Set("User-Agent", s.userAgent())
+    resp, err := s.httpClient().Do(req)
+    if err != nil {
+        return "", fmt.Errorf("failed to fetch schema '%s': %v", source, err)
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        return "", fmt.Errorf("failed to fetch schema '%s': unexpected status %s", source, resp.Status)
+    }
+    data, err := io.ReadAll(resp.Body)
+    if err != nil {
+        return "", fmt.Errorf("failed to fetch schema '%s': %v", source, err)
+    }
+
+    // Renamed into place, so that an interrupted or concurrent download
+    // never leaves a truncated schema in the cache
+    if err := os.MkdirAll(cacheDir, 0755); err != nil {
+        return "", err
+    }
+    tmp, err := os.CreateTemp(cacheDir, filepath.Base(filePath)+".tmp*")
+    if err != nil {
+        return "", err
+    }
+    defer os.Remove(tmp.Name())
+    if _, err := tmp.Write(data); err != nil {
+        tmp.Close()
+        return "", err
+    }
+    if err := tmp.Close(); err != nil {
+        return "", err
+    }
+    if err := os.Chmod(tmp.Name(), 0644); err != nil {
+        return "", err
+    }
+    if err := os.Rename(tmp.Name(), filePath); err != nil {
+        return "", err
+    }
+    return filePath, nil
+}
+
+// schemaTargetNamespace extracts the targetNamespace of an XSD document.
+func schemaTargetNamespace(data []byte) (string, error) {
+    decoder := xml.NewDecoder(bytes.NewReader(data))
+    for {
+        token, err := decoder.Token()
+        if err != nil {
+            return "", err
+        }
+        if start, ok := token.(xml.StartElement); ok {
+            for _, attr := range start.Attr {
+                if attr.Name.Local == "targetNamespace" {
+                    return attr.Value, nil
+                }
+            }
+            return "", fmt.Errorf("schema root <%s> has no targetNamespace", start.Name.Local)
+        }
+    }
+}
+
+// extensionSchemas returns the namespaces defined by SchemaSources when
+// RequireExtensionSchema is set, nil otherwise.
+func (s *SitemapOptions) extensionSchemas() (map[string]bool, error) {
+    if !s.RequireExtensionSchema {
+        return nil, nil
+    }
+    covered := map[string]bool{}
+    for _, source := range s.SchemaSources {
+        _, namespace, err := s.readSchemaSource(source)
+        if err != nil {
+            return nil, err
+        }
+        covered[namespace] = true
+    }
+    return covered, nil
+}
+
+// checkExtensionSchema ensures every extension namespace used by u is in
+// covered. A nil covered map disables the check.
+func checkExtensionSchema(u SitemapURL, covered map[string]bool) error {
+    if covered == nil {
+        return nil
+    }
+    if (u.AMPURL != "" || len(u.Alternates) > 0) && !covered[xhtmlNamespace] {
+        return fmt.Errorf("no schema configured for namespace '%s' used by '%s'", xhtmlNamespace, u.Loc)
+    }
+    if len(u.Images) > 0 && !covered[imageNamespace] {
+        return fmt.Errorf("no schema configured for namespace '%s' used by '%s'", imageNamespace, u.Loc)
+    }
+    for _, ext := range u.Extensions {
+        if !covered[ext.XMLName.Space] {
+            return fmt.Errorf("no schema configured for namespace '%s' used by '%s'", ext.XMLName.Space, u.Loc)
+        }
+    }
+    return nil
+}
@@ -0,0 +1,47 @@
+package nyxsitemap
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "testing"
+)
+
+func TestRemoteSchemaSource(t *testing.T) {
+    schema, err := os.ReadFile("testdata/sitemap-image.xsd")
+    if err != nil {
+        t.Fatalf("Error reading schema: %v", err)
+    }
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if r.Header.Get("User-Agent") != "test-agent" {
+            t.Errorf("Unexpected User-Agent: %s", r.Header.Get("User-Agent"))
+        }
+        w.Write(schema)
+    }))
+    defer server.Close()
+
+    sm := NewSitemapOptions("./test_sitemaps_remote_schema", "https://www.example.com")
+    sm.HTTPClient = server.Client()
+    sm.UserAgent = "test-agent"
+
+    // Remote schemas are downloaded with HTTPClient and UserAgent
+    filePath, namespace, err := sm.readSchemaSource(server.URL + "/sitemap-image.xsd")
+    if err != nil {
+        t.Fatalf("Error fetching remote schema: %v", err)
+    }
+    defer os.Remove(filePath)
+    if namespace != imageNamespace {
+        t.Fatalf("Expected the image namespace, got '%s'", namespace)
+    }
+
+    // The cached copy is complete and no temporary file is left behind
+    cached, err := os.ReadFile(filePath)
+    if err != nil || string(cached) != string(schema) {
+        t.Fatalf("Expected the whole schema to be cached, got %d bytes, %v", len(cached), err)
+    }
+    leftovers, _ := filepath.Glob(filePath + ".tmp*")
+    if len(leftovers) != 0 {
+        t.Fatalf("Expected no temporary files left, got %v", leftovers)
+    }
+}
@@ -4,20 +4,25 @@ import (
     "bytes"
     "encoding/xml"
     "fmt"
+    "math"
+    "net/http"
     "net/url"
-    "os"
     "path"
+    "regexp"
+    "sort"
+    "strconv"
     "strings"
     "time"
-
-    "github.com/lestrrat-go/libxml2"
-    "github.com/lestrrat-go/libxml2/xsd"
+    "unicode"
+    "unicode/utf8"
 )
 
 const (
     sitemapExt = ".xml"
     // Reduced max URLs by 1/3 for safety
     maxURLsPerSitemap = 33333
+    // Max URLs per sitemap allowed by the protocol
+    protocolMaxURLs = 50000
     // Sitemap XSD schema for validation
     sitemapXSD = `<?xml version="1.0" encoding="UTF-8"?>
 <xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"
@@ -31,7 +36,11 @@ const (
           <xs:complexType>
             <xs:sequence>
               <xs:element name="loc" type="xs:anyURI" />
-              <xs:element name="lastmod" type="xs:date" minOccurs="0" />
+              <xs:element name="lastmod" minOccurs="0">
+                <xs:simpleType>
+                  <xs:union memberTypes="xs:date xs:dateTime" />
+                </xs:simpleType>
+              </xs:element>
               <xs:element name="changefreq" minOccurs="0">
                 <xs:simpleType>
                   <xs:restriction base="xs:string">
@@ -53,10 +62,12 @@ const (
                   </xs:restriction>
                 </xs:simpleType>
               </xs:element>
+              <xs:any namespace="##other" processContents="lax" minOccurs="0" maxOccurs="unbounded" />
             </xs:sequence>
           </xs:complexType>
         </xs:element>
       </xs:sequence>
+      <xs:anyAttribute namespace="##other" processContents="lax" />
     </xs:complexType>
   </xs:element>
 </xs:schema>
@@ -74,7 +85,11 @@ const (
           <xs:complexType>
             <xs:sequence>
               <xs:element name="loc" type="xs:anyURI" />
-              <xs:element name="lastmod" type="xs:date" minOccurs="0" />
+              <xs:element name="lastmod" minOccurs="0">
+                <xs:simpleType>
+                  <xs:union memberTypes="xs:date xs:dateTime" />
+                </xs:simpleType>
+              </xs:element>
             </xs:sequence>
           </xs:complexType>
         </xs:element>
@@ -124,25 +139,81 @@ const (
 
 // SitemapURL represents a single URL entry in the sitemap.
 type SitemapURL struct {
-    XMLName    xml.Name `xml:"url"`
-    Loc        string   `xml:"loc"`
-    LastMod    string   `xml:"lastmod,omitempty"`
-    ChangeFreq string   `xml:"changefreq,omitempty"`
-    Priority   string   `xml:"priority,omitempty"`
+    XMLName    xml.Name    `xml:"url"`
+    Loc        string      `xml:"loc"`
+    LastMod    string      `xml:"lastmod,omitempty"`
+    ChangeFreq string      `xml:"changefreq,omitempty"`
+    Priority   string      `xml:"priority,omitempty"`
+    Extensions []Extension `xml:",any"`
+
+    // LastModTime is used by AddURL as the lastmod when LastMod is empty,
+    // formatted as a W3C datetime, or as a date when it has no time of day.
+    // It isn't emitted itself.
+    LastModTime time.Time `xml:"-"`
+
+    // FeedURL tags RSS/Atom feed URLs for tooling. It isn't emitted.
+    FeedURL bool `xml:"-"`
+
+    // AMPURL is the AMP version of the page, resolved against BaseURL and
+    // emitted as an xhtml:link alternate with rel="amphtml".
+    AMPURL string `xml:"-"`
+
+    // Images are emitted as image:image extensions, declaring the image
+    // namespace on <urlset>.
+    Images []SitemapImage `xml:"-"`
+
+    // Alternates are emitted as xhtml:link alternates with their hreflang,
+    // declaring the xhtml namespace on <urlset>.
+    Alternates []Alternate `xml:"-"`
 }
 
 // URLSet represents a collection of SitemapURLs.
 type URLSet struct {
     XMLName xml.Name     `xml:"urlset"`
     Xmlns   string       `xml:"xmlns,attr"`
+    Attrs   []xml.Attr   `xml:",any,attr"`
     URLs    []SitemapURL `xml:"url"`
 }
 
+// explicitURL is a SitemapURL marshaled without omitting its empty fields,
+// used with EmitEmptyFields.
+type explicitURL struct {
+    XMLName    xml.Name    `xml:"url"`
+    Loc        string      `xml:"loc"`
+    LastMod    string      `xml:"lastmod"`
+    ChangeFreq string      `xml:"changefreq"`
+    Priority   string      `xml:"priority"`
+    Extensions []Extension `xml:",any"`
+}
+
+// explicitURLSet is the URLSet marshaled with EmitEmptyFields.
+type explicitURLSet struct {
+    XMLName xml.Name      `xml:"urlset"`
+    Xmlns   string        `xml:"xmlns,attr"`
+    Attrs   []xml.Attr    `xml:",any,attr"`
+    URLs    []explicitURL `xml:"url"`
+}
+
+// explicit returns the URL set in the form keeping its empty fields.
+func (urlSet URLSet) explicit() explicitURLSet {
+    urls := make([]explicitURL, len(urlSet.URLs))
+    for i, u := range urlSet.URLs {
+        urls[i] = explicitURL{
+            Loc:        u.Loc,
+            LastMod:    u.LastMod,
+            ChangeFreq: u.ChangeFreq,
+            Priority:   u.Priority,
+            Extensions: u.Extensions,
+        }
+    }
+    return explicitURLSet{Xmlns: urlSet.Xmlns, Attrs: urlSet.Attrs, URLs: urls}
+}
+
 // Sitemap represents a sitemap file entry in the sitemap index.
 type Sitemap struct {
-    XMLName xml.Name `xml:"sitemap"`
-    Loc     string   `xml:"loc"`
-    LastMod string   `xml:"lastmod,omitempty"`
+    XMLName xml.Name `xml:"sitemap" json:"-"`
+    Loc     string   `xml:"loc" json:"loc"`
+    LastMod string   `xml:"lastmod,omitempty" json:"lastmod,omitempty"`
 }
 
 // SitemapIndex represents a collection of sitemaps.
@@ -152,6 +223,105 @@ type SitemapIndex struct {
     Sitemaps []Sitemap `xml:"sitemap"`
 }
 
+// DirLayout controls how sitemap files are arranged inside Dir.
+type DirLayout int
+
+const (
+    // Flat writes every sitemap file directly into Dir.
+    Flat DirLayout = iota
+    // ByYear partitions URLs by lastmod year into subdirectories of Dir
+    // (e.g. 2023/sitemap_1.xml), always referenced from a sitemap index.
+    ByYear
+)
+
+// String returns the name of the layout constant.
+func (l DirLayout) String() string {
+    switch l {
+    case Flat:
+        return "Flat"
+    case ByYear:
+        return "ByYear"
+    }
+    return fmt.Sprintf("DirLayout(%d)", int(l))
+}
+
+// IndexOrder controls the order of entries in the sitemap index.
+type IndexOrder int
+
+const (
+    // Numeric lists shards in the order they were split, sitemap_1.xml first.
+    Numeric IndexOrder = iota
+    // LastModDesc lists shards with the newest URL lastmod first.
+    LastModDesc
+    // URLCountDesc lists shards holding the most URLs first.
+    URLCountDesc
+)
+
+// String returns the name of the order constant.
+func (o IndexOrder) String() string {
+    switch o {
+    case Numeric:
+        return "Numeric"
+    case LastModDesc:
+        return "LastModDesc"
+    case URLCountDesc:
+        return "URLCountDesc"
+    }
+    return fmt.Sprintf("IndexOrder(%d)", int(o))
+}
+
+// SplitMode controls how URLs are split into shards.
+type SplitMode int
+
+const (
+    // FixedCount puts MaxURLs URLs in every shard, cutting it short when
+    // the next URL would take it past MaxFileSize bytes.
+    FixedCount SplitMode = iota
+    // Adaptive fills every shard up to MaxFileSize bytes, capped by the
+    // protocol's 50,000 URLs, regardless of MaxURLs.
+    Adaptive
+)
+
+// String returns the name of the mode constant.
+func (m SplitMode) String() string {
+    switch m {
+    case FixedCount:
+        return "FixedCount"
+    case Adaptive:
+        return "Adaptive"
+    }
+    return fmt.Sprintf("SplitMode(%d)", int(m))
+}
+
+// DuplicateHreflang controls what Write does with alternates of a URL that
+// repeat an hreflang, which search engines consider invalid.
+type DuplicateHreflang int
+
+const (
+    // RejectDuplicateHreflang makes Write fail on a repeated hreflang.
+    RejectDuplicateHreflang DuplicateHreflang = iota
+    // KeepFirstHreflang keeps the first alternate for each hreflang,
+    // dropping the others with a warning.
+    KeepFirstHreflang
+)
+
+// String returns the name of the handling constant.
+func (d DuplicateHreflang) String() string {
+    switch d {
+    case RejectDuplicateHreflang:
+        return "RejectDuplicateHreflang"
+    case KeepFirstHreflang:
+        return "KeepFirstHreflang"
+    }
+    return fmt.Sprintf("DuplicateHreflang(%d)", int(d))
+}
+
+// sitemapShard is a sitemap file referenced by the index and its URLs.
+type sitemapShard struct {
+    name string
+    urls []SitemapURL
+}
+
 // SitemapOptions holds configuration for generating sitemaps.
 type SitemapOptions struct {
     MaxFileSize int
@@ -159,7 +329,275 @@ type SitemapOptions struct {
     Dir         string
     BaseURL     string
     URLs        []SitemapURL
-    Stylesheet  string // Holds the stylesheet filename
+    Stylesheet  string     // Holds the stylesheet filename
+    DirLayout   DirLayout  // Flat by default
+    FS          FileSystem // Local disk when nil
+
+    // DuplicateHreflang controls the handling of alternates repeating an
+    // hreflang of the same URL, compared case-insensitively. Rejected by
+    // default.
+    DuplicateHreflang DuplicateHreflang
+
+    // IncludeStylesheet controls whether the stylesheet is written and
+    // referenced from the generated files, e.g. only in development.
+    IncludeStylesheet bool
+
+    // PriorityDecay, when set, computes the priority of URLs without an
+    // explicit one from the age of their lastmod. Results are clamped to [0,1].
+    PriorityDecay func(age time.Duration) float64
+
+    // PriorityFloor omits the priority of URLs whose explicit or decayed
+    // priority is below it, leaving crawlers to assume the 0.5 default.
+    PriorityFloor float64
+
+    // EmitDefaultPriority emits the 0.5 priority crawlers assume for URLs
+    // without one, including those omitted by PriorityFloor, for tooling
+    // that shouldn't have to know the default.
+    EmitDefaultPriority bool
+
+    // SchemaSources lists XSD files or URLs that sitemap files are validated
+    // against instead of the bundled schema, e.g. the official sitemaps.org
+    // schema plus the extension schemas in use. Index files keep using the
+    // bundled index schema. Validating against them needs cgo: builds
+    // without it fail to write sitemap files when they are set.
+    SchemaSources []string
+
+    // IndexThreshold is the URL count above which an index is written.
+    // Defaults to MaxURLs when zero; shards still hold up to MaxURLs each.
+    IndexThreshold int
+
+    // FileHeaders are HTTP header hints attached to every generated file's
+    // FileInfo, e.g. Cache-Control for a CDN uploader. "{filename}" in a
+    // value is replaced with the file's name.
+    FileHeaders map[string]string
+
+    // DeduplicateURLs makes Write keep a single entry per resolved loc, the
+    // last one added so that the freshest lastmod wins, at the position of
+    // the first. URLs itself keeps every entry. Not applied by WriteSource.
+    DeduplicateURLs bool
+
+    // Concurrency is how many shards of an index are written, and then
+    // validated, at once. Zero means GOMAXPROCS. Shards are written one at a
+    // time with MaxTotalBytes. The phase durations in Stats are scaled to
+    // the wall-clock time taken. BytesWritten, SkipValidationFor and
+    // Validator are called from several goroutines and must be safe for
+    // concurrent use.
+    Concurrency int
+
+    // CheckGlobalUniqueness makes Write fail when the same resolved loc
+    // ends up in more than one shard of an index.
+    CheckGlobalUniqueness bool
+
+    // RoundTripVerify reads every sitemap file back after writing it and
+    // checks that it parses into the URLs written, catching encoding bugs
+    // the schema can't see.
+    RoundTripVerify bool
+
+    // VerifySplit is a debugging aid making Write check that the shards of
+    // an index hold exactly the URLs being written, each once, e.g. while
+    // developing a ShardFilename hook or with the Adaptive SplitMode.
+    VerifySplit bool
+
+    // SelfClosingEmpty emits empty elements as <x/> instead of the <x></x>
+    // pairs produced by encoding/xml.
+    SelfClosingEmpty bool
+
+    // EmitEmptyFields emits lastmod, changefreq and priority elements even
+    // when their value is empty, for consumers expecting every element; see
+    // SelfClosingEmpty for their form. The bundled schema rejects empty
+    // changefreq and priority values, so such files need SchemaSources or
+    // SkipValidationFor.
+    EmitEmptyFields bool
+
+    // GeneratorTag, e.g. "nyxsitemap 1.2.3", is written into every file as
+    // a <?generator ...?> processing instruction after the XML declaration,
+    // for tracing files back to the tool that produced them.
+    GeneratorTag string
+
+    // Gzip writes the sitemap files gzip-compressed, as sitemap.xml.gz and
+    // sitemap_N.xml.gz, and has the index reference them by those names.
+    // GzipIndex also compresses the index, as sitemap_index.xml.gz.
+    // MaxFileSize still bounds the uncompressed size, as search engines do.
+    Gzip      bool
+    GzipIndex bool
+
+    // GzipKeepExtension keeps the plain .xml names of gzipped files, in
+    // Files and in the index, for servers sending them with a gzip
+    // Content-Encoding instead.
+    GzipKeepExtension bool
+
+    // Compact writes the root element on a single line, without
+    // indentation. The declaration and stylesheet reference still take a
+    // line each.
+    Compact bool
+
+    // Validate controls whether written files are read back and validated,
+    // true by default. When disabled, Write skips validation entirely,
+    // including the checks of DropInvalidShards.
+    Validate bool
+
+    // Validator, when set, replaces the XSD validation of documents, e.g.
+    // with ValidateStructure. CacheValidation doesn't apply to it.
+    Validator func(data []byte, isIndex bool) error
+
+    // CacheValidation skips revalidating files whose content was already
+    // validated in this process, among the latest 10,000 results. See
+    // ClearValidationCache.
+    CacheValidation bool
+
+    // RequireCleanDir makes Write fail before writing anything when Dir
+    // holds files other than sitemaps with the default names and the
+    // stylesheet, e.g. when pointed at the wrong directory. Subdirectories
+    // are checked too. Names given by ShardFilename aren't recognized.
+    RequireCleanDir bool
+
+    // CreateDir creates Dir when it doesn't exist. When false, a missing
+    // Dir is an error, guarding against writing to a mistyped path.
+    CreateDir bool
+
+    // SelfReferenceIndex adds an entry for the index's own URL to the
+    // index, for consumers following discovery chains.
+    SelfReferenceIndex bool
+
+    // Dirs lists extra directories, e.g. a backup mount, that receive a
+    // copy of every file once it has been written and validated in Dir.
+    Dirs []string
+
+    // Canonical makes identical input produce byte-identical output, for
+    // committing sitemaps to version control: URLs are written sorted by
+    // loc, except by WriteSource, and index lastmods come from the newest
+    // URL lastmod instead of today.
+    Canonical bool
+
+    // RecordChanges keeps an audit trail of the values the package alters
+    // in URLs, returned by Changes. It grows with every altered URL, so it
+    // is off by default.
+    RecordChanges bool
+
+    // CanonicalHost, e.g. "https://www.example.com", replaces the scheme
+    // and host of every resolved loc, keeping its path and query, so locs
+    // stored with a staging host are emitted with the production one. Image
+    // locs, alternate hrefs and AMPURLs on the host of their loc are
+    // rewritten too; those on other hosts, e.g. a CDN, are left as is.
+    CanonicalHost string
+
+    // ForceBasePath prepends the path of BaseURL, e.g. "/app", to absolute
+    // locs on its host that lack it, for callers passing absolute locs
+    // relative to the site root rather than to the app.
+    ForceBasePath bool
+
+    // Transforms are applied in order to every URL at write time, after its
+    // loc is resolved against BaseURL. A transform returning false drops
+    // the URL.
+    Transforms []func(SitemapURL) (SitemapURL, bool)
+
+    // MaxQueryParams drops URLs with more than this many query parameters
+    // at write time, e.g. faceted-navigation pages, with a warning for
+    // each. Zero means no limit.
+    MaxQueryParams int
+
+    // RoundPriority makes AddURLValidated round priorities with more than
+    // one decimal place instead of rejecting them.
+    RoundPriority bool
+
+    // MaxTotalBytes stops writing shards once the next one would take the
+    // files written by the run past this many bytes. The index then only
+    // references the shards written, and OmittedURLs reports the URLs left
+    // out. The index itself is not counted. Zero means no limit.
+    MaxTotalBytes int
+
+    // ShardFilename, when set, names the n-th (1-based) shard given its
+    // URLs, instead of sitemap_N.xml. Names are paths relative to Dir and
+    // must be unique. It may be called several times per shard.
+    ShardFilename func(n int, urls []SitemapURL) string
+
+    // SplitMode sets how URLs are split into shards, FixedCount by default.
+    SplitMode SplitMode
+
+    // IndexOrder sets the order of index entries, Numeric by default.
+    IndexOrder IndexOrder
+
+    // HTMLMaxEntries caps the number of links WriteHTML lists. Zero means
+    // no limit.
+    HTMLMaxEntries int
+
+    // RequireExtensionSchema makes writes fail when a URL carries an
+    // extension (or AMPURL) whose namespace no SchemaSources entry
+    // defines, instead of letting the bundled schema skip it.
+    RequireExtensionSchema bool
+
+    // SkipValidationFor, when set, is asked for each written file, by name
+    // relative to Dir, whether its validation can be skipped, e.g. for
+    // shards known to be unchanged since they were last validated.
+    SkipValidationFor func(filename string) bool
+
+    // DropInvalidShards makes a shard failing validation get left out of
+    // the index, which is rewritten without it, instead of failing Write.
+    // Dropped shards are reported in Warnings and left out of Files; the
+    // file itself stays in Dir.
+    DropInvalidShards bool
+
+    // BytesWritten, when set, is called with the size of each file written
+    // into Dir, e.g. to drive a byte-granular progress bar.
+    BytesWritten func(n int64)
+
+    // BuildTime, when set, is used instead of the current time wherever a
+    // date defaults to today, e.g. for URLs added without a lastmod and for
+    // index lastmods, so that a whole generation carries the same date.
+    // Lastmods are dates, so only its date in TimeZone is emitted.
+    BuildTime time.Time
+
+    // MinLastMod is the oldest lastmod AddURL accepts. Older dates, e.g.
+    // zero Unix timestamps, are bumped to it. The zero value disables it.
+    MinLastMod time.Time
+
+    // TimeZone is the zone "today" is taken in when AddURL fills in or caps
+    // a lastmod, and for the other dates defaulting to today, as well as the
+    // zone of the commit dates of AddURLFromGit. Nil means UTC.
+    TimeZone *time.Location
+
+    // MaxURLsInMemory caps how many URLs AddURL and AddURLs hold, erroring
+    // once it would be exceeded, e.g. to protect against an untrusted feed
+    // exhausting memory. WriteSource streams URLs instead. Zero means no cap.
+    MaxURLsInMemory int
+
+    // URLSetAttrs are extra attributes emitted on the <urlset> root, sorted
+    // by name, e.g. namespace declarations ("xmlns:vendor") or namespaced
+    // vendor attributes ("vendor:build").
+    URLSetAttrs map[string]string
+
+    // NamespaceOrder lists the prefixes whose xmlns declarations come first
+    // on <urlset>, right after the default namespace, in this order, for
+    // validators strict about it. Nil means image, video, news, xhtml.
+    // Other attributes follow, sorted by name.
+    NamespaceOrder []string
+
+    // HTTPClient sends the requests of Ping and downloads remote
+    // SchemaSources. Defaults to http.DefaultClient when nil; tests can
+    // point it at an httptest server.
+    HTTPClient *http.Client
+
+    // HTTPLimiter, when set, bounds the concurrent requests of Ping, which
+    // otherwise pings every engine at once. Sharing one limiter, e.g. from
+    // NewLimiter, between SitemapOptions bounds their requests combined.
+    HTTPLimiter Limiter
+
+    // UserAgent is the User-Agent header of outgoing HTTP requests, some
+    // endpoints rejecting Go's default. Defaults to "nyxsitemap/<version>"
+    // when empty, the version being that of the module in the build.
+    UserAgent string
+
+    files    []FileInfo
+    stats    Stats
+    index    *SitemapIndex    // Index written by the last run, if any
+    warnings []string
+    omitted  int              // URLs left out by the last run to honor MaxTotalBytes
+    topURL   string           // URL of the index or single sitemap of the last run
+    changes  []URLChange
+    ended    int              // Length of changes when the last run ended
+    prepared []SitemapURL     // URLs as written by the last run, see prepareURLs
+    split    []sitemapShard   // Shards of prepared, computed once per run
+    clock    func() time.Time // Overrides time.Now in tests
 }
 
 // NewSitemapOptions initializes a new SitemapOptions instance.
@@ -171,230 +609,1475 @@ func NewSitemapOptions(dir string, baseURL string) *SitemapOptions {
         BaseURL:     strings.TrimRight(baseURL, "/"),
         URLs:        []SitemapURL{},
         Stylesheet:  "sitemap.xsl", // Default stylesheet filename
+
+        IncludeStylesheet: true,
+        CreateDir:         true,
+        Validate:          true,
+    }
+}
+
+// AddURL adds a single SitemapURL to the sitemap, ensuring it's valid. It
+// fails, adding nothing, when URLs already holds MaxURLsInMemory URLs.
+func (s *SitemapOptions) AddURL(url SitemapURL) error {
+    if err := s.checkURLsInMemory(1); err != nil {
+        return err
+    }
+    s.URLs = append(s.URLs, s.normalizeURL(url))
+    return nil
+}
+
+// checkURLsInMemory checks that adding n URLs keeps URLs within
+// MaxURLsInMemory.
+func (s *SitemapOptions) checkURLsInMemory(n int) error {
+    if s.MaxURLsInMemory > 0 && len(s.URLs)+n > s.MaxURLsInMemory {
+        return fmt.Errorf("adding %d URLs to the %d held would exceed MaxURLsInMemory (%d), use WriteSource to stream them instead",
+            n, len(s.URLs), s.MaxURLsInMemory)
+    }
+    return nil
+}
+
+// lastModDate returns the calendar date of a lastmod given either as a date
+// or as a full datetime, the two forms the sitemap schema accepts.
+func lastModDate(lastMod string) (time.Time, error) {
+    if t, err := time.Parse("2006-01-02", lastMod); err == nil {
+        return t, nil
+    }
+    t, err := time.Parse(time.RFC3339, lastMod)
+    if err != nil {
+        return time.Time{}, err
+    }
+    return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
+}
+
+// formatLastMod formats t as a W3C datetime, or as a date when t is at
+// midnight, e.g. when it was parsed from a date.
+func formatLastMod(t time.Time) string {
+    if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0 {
+        return t.Format("2006-01-02")
     }
+    return t.Format(time.RFC3339)
 }
 
-// AddURL adds a single SitemapURL to the sitemap, ensuring it's valid.
-func (s *SitemapOptions) AddURL(url SitemapURL) {
+// normalizeURL applies the fixes AddURL makes to incoming URLs.
+func (s *SitemapOptions) normalizeURL(url SitemapURL) SitemapURL {
+    if url.LastMod == "" && !url.LastModTime.IsZero() {
+        url.LastMod = formatLastMod(url.LastModTime)
+    }
+    today := s.today()
+    original := url.LastMod
+    reason := ""
     if url.LastMod == "" {
-        url.LastMod = time.Now().UTC().Format("2006-01-02")
+        url.LastMod, reason = today, "missing lastmod set to today"
     } else {
-        timeLastMod, err := time.Parse("2006-01-02", url.LastMod)
-        if err != nil || timeLastMod.After(time.Now().UTC()) {
-            url.LastMod = time.Now().UTC().Format("2006-01-02")
+        timeLastMod, err := lastModDate(url.LastMod)
+        if err != nil {
+            url.LastMod, reason = today, "invalid lastmod replaced with today"
+        } else if timeLastMod.Format("2006-01-02") > today {
+            url.LastMod, reason = today, "future lastmod capped to today"
+        } else if timeLastMod.Before(s.MinLastMod) {
+            url.LastMod, reason = s.MinLastMod.Format("2006-01-02"), "lastmod raised to MinLastMod"
+        }
+    }
+    s.recordChange(url.Loc, "lastmod", original, url.LastMod, reason)
+    return url
+}
+
+// today returns the current date, or the date of BuildTime, in TimeZone,
+// UTC by default.
+func (s *SitemapOptions) today() string {
+    now := time.Now()
+    if !s.BuildTime.IsZero() {
+        now = s.BuildTime
+    } else if s.clock != nil {
+        now = s.clock()
+    }
+    return s.date(now)
+}
+
+// date formats t as a lastmod date in TimeZone, UTC by default.
+func (s *SitemapOptions) date(t time.Time) string {
+    loc := s.TimeZone
+    if loc == nil {
+        loc = time.UTC
+    }
+    return t.In(loc).Format("2006-01-02")
+}
+
+// AddURLValidated adds a URL like AddURL, but first checks that its priority
+// is a decimal in [0.0, 1.0] with at most one decimal place, as search
+// engines expect. With RoundPriority set, extra decimals are rounded half
+// away from zero (e.g. "0.55" becomes "0.6") instead of being rejected.
+func (s *SitemapOptions) AddURLValidated(url SitemapURL) error {
+    if url.Priority != "" {
+        value, err := strconv.ParseFloat(url.Priority, 64)
+        if err != nil || value < 0 || value > 1 {
+            return fmt.Errorf("invalid priority '%s' for '%s': must be a decimal between 0.0 and 1.0", url.Priority, url.Loc)
+        }
+        if dot := strings.IndexByte(url.Priority, '.'); dot >= 0 && len(url.Priority)-dot-1 > 1 {
+            if !s.RoundPriority {
+                return fmt.Errorf("invalid priority '%s' for '%s': at most one decimal place is allowed", url.Priority, url.Loc)
+            }
+            rounded := strconv.FormatFloat(math.Round(value*10)/10, 'f', 1, 64)
+            s.recordChange(url.Loc, "priority", url.Priority, rounded, "priority rounded to one decimal place")
+            url.Priority = rounded
+        }
+    }
+    return s.AddURL(url)
+}
+
+// AddURLChecked adds a URL like AddURLValidated, but first also checks that
+// its loc is set and resolves against BaseURL to an http(s) URL, and that
+// its changefreq, if any, is one of the protocol values, so that bad records
+// are reported as they are added rather than when Write validates the file.
+func (s *SitemapOptions) AddURLChecked(url SitemapURL) error {
+    if strings.TrimSpace(url.Loc) == "" {
+        return fmt.Errorf("missing loc")
+    }
+    resolved, err := s.resolveURL(url.Loc)
+    if err != nil {
+        return fmt.Errorf("invalid loc '%s': %v", url.Loc, err)
+    }
+    if err := checkStructure(resolved, "", "", ""); err != nil {
+        return err
+    }
+    if url.ChangeFreq != "" && !changeFreqs[url.ChangeFreq] {
+        return fmt.Errorf("invalid changefreq '%s' for '%s': must be always, hourly, daily, weekly, monthly, yearly or never", url.ChangeFreq, url.Loc)
+    }
+    return s.AddURLValidated(url)
+}
+
+// Feeds returns the URLs tagged as RSS/Atom feeds.
+func (s *SitemapOptions) Feeds() []SitemapURL {
+    var feeds []SitemapURL
+    for _, u := range s.URLs {
+        if u.FeedURL {
+            feeds = append(feeds, u)
         }
     }
-    s.URLs = append(s.URLs, url)
+    return feeds
 }
 
 // AddURLs adds multiple SitemapURLs to the sitemap, ensuring they're valid.
-func (s *SitemapOptions) AddURLs(urls []SitemapURL) {
+// When the batch would take URLs past MaxURLsInMemory, none of it is added.
+func (s *SitemapOptions) AddURLs(urls []SitemapURL) error {
+    if err := s.checkURLsInMemory(len(urls)); err != nil {
+        return err
+    }
     for _, url := range urls {
-        s.AddURL(url)
+        s.URLs = append(s.URLs, s.normalizeURL(url))
     }
+    return nil
 }
 
 // Write generates the sitemap files based on the current URLs.
 // baseSitemapURL is the base URL where the sitemap files will be accessible.
 func (s *SitemapOptions) Write(baseSitemapURL string) error {
-    // Ensure the directory exists
-    if _, err := os.Stat(s.Dir); os.IsNotExist(err) {
-        if err := os.MkdirAll(s.Dir, 0755); err != nil {
+    s.resetRun()
+    defer s.endRun(time.Now())
+
+    if err := s.write(baseSitemapURL); err != nil {
+        return err
+    }
+    if err := s.mirrorFiles(); err != nil {
+        return err
+    }
+    return s.recordTopURL(baseSitemapURL)
+}
+
+// resetRun clears what the previous run recorded.
+func (s *SitemapOptions) resetRun() {
+    s.files = nil
+    s.stats = Stats{}
+    s.index = nil
+    s.warnings = nil
+    s.omitted = 0
+    s.topURL = ""
+    s.prepared = nil
+    s.split = nil
+    s.changes = s.changes[s.ended:]
+    s.ended = 0
+}
+
+// endRun records the duration of the run started at start, and marks the
+// end of its changes so that the next run drops them.
+func (s *SitemapOptions) endRun(start time.Time) {
+    addSince(&s.stats.TotalDuration, start)
+    s.ended = len(s.changes)
+}
+
+// OmittedURLs returns how many URLs the last Write left out to stay within
+// MaxTotalBytes.
+func (s *SitemapOptions) OmittedURLs() int {
+    return s.omitted
+}
+
+// write generates and validates the sitemap files in Dir.
+func (s *SitemapOptions) write(baseSitemapURL string) error {
+    if err := s.prepareURLs(); err != nil {
+        return err
+    }
+
+    // The index references its sitemap files by absolute URL
+    isIndex := s.needsIndex()
+    if isIndex {
+        if err := checkBaseSitemapURL(baseSitemapURL); err != nil {
             return err
         }
     }
 
-    // Write the stylesheet into the sitemap directory
-    if err := s.writeStylesheet(); err != nil {
+    // Ensure the directory exists
+    if err := s.ensureDir(); err != nil {
         return err
     }
 
-    // Prepare URLs
-    for i := range s.URLs {
-        fullURL, err := s.resolveURL(s.URLs[i].Loc)
-        if err != nil {
+    // Write the stylesheet into the sitemap directory
+    if s.IncludeStylesheet {
+        if err := s.writeStylesheet(); err != nil {
             return err
         }
-        s.URLs[i].Loc = fullURL
     }
 
     // Decide whether to create a sitemap index or a single sitemap
-    if len(s.URLs) <= s.MaxURLs {
+    if !isIndex {
         // Generate sitemap file
-        err := s.writeSitemapFile("sitemap.xml", s.URLs)
+        err := s.writeSitemapFile(s.singleName(), s.prepared)
         if err != nil {
             return err
         }
         // Validate the generated sitemap file
-        return s.validateXMLFile(path.Join(s.Dir, "sitemap.xml"), false)
+        return s.validateXMLFile(path.Join(s.Dir, s.singleName()), false)
     } else {
+        if s.CheckGlobalUniqueness {
+            if err := checkShardUniqueness(s.shards()); err != nil {
+                return err
+            }
+        }
+
         // Generate sitemap index
         err := s.writeSitemapIndex(baseSitemapURL)
         if err != nil {
             return err
         }
         // Validate the sitemap index and all sitemap files
-        return s.validateSitemapIndexAndFiles()
+        if !s.Validate {
+            return nil
+        }
+        return s.validateSitemapIndexAndFiles(baseSitemapURL)
     }
 }
 
-func (s *SitemapOptions) resolveURL(loc string) (string, error) {
-    base, err := url.Parse(s.BaseURL)
-    if err != nil {
-        return "", err
-    }
-    ref, err := url.Parse(loc)
+// prepareURLs prepares a copy of URLs for writing into prepared, dropping
+// the ones excluded by the options, and puts them in their final order.
+// URLs itself is left untouched, so every run starts from the same URLs.
+func (s *SitemapOptions) prepareURLs() error {
+    covered, err := s.extensionSchemas()
     if err != nil {
-        return "", err
+        return err
     }
-    return base.ResolveReference(ref).String(), nil
-}
 
-func (s *SitemapOptions) resolveSitemapURL(baseSitemapURL, sitemapName string) (string, error) {
-    base, err := url.Parse(strings.TrimRight(baseSitemapURL, "/") + "/")
-    if err != nil {
-        return "", err
+    kept := make([]SitemapURL, 0, len(s.URLs))
+    positions := map[string]int{}
+    for _, u := range s.URLs {
+        if err := s.prepareURL(&u); err != nil {
+            return err
+        }
+        u, ok := s.transformURL(u)
+        if !ok {
+            continue
+        }
+        if err := checkExtensionSchema(u, covered); err != nil {
+            return err
+        }
+        s.checkURL(u)
+        if s.DeduplicateURLs {
+            // The last entry for a loc wins, in the place of the first
+            if j, ok := positions[u.Loc]; ok {
+                kept[j] = u
+                continue
+            }
+            positions[u.Loc] = len(kept)
+        }
+        kept = append(kept, u)
     }
-    ref, err := url.Parse(sitemapName)
-    if err != nil {
-        return "", err
+    if s.Canonical {
+        sort.SliceStable(kept, func(i, j int) bool {
+            return kept[i].Loc < kept[j].Loc
+        })
     }
-    return base.ResolveReference(ref).String(), nil
+    s.prepared = kept
+    s.split = nil
+    return nil
 }
 
-func (s *SitemapOptions) writeStylesheet() error {
-    filePath := path.Join(s.Dir, s.Stylesheet)
-    return os.WriteFile(filePath, []byte(sitemapXSL), 0644)
+// preparedCopy returns a copy of s with URLs prepared as Write prepares
+// them, outside of any run: no changes or warnings are recorded on s.
+func (s *SitemapOptions) preparedCopy() (*SitemapOptions, error) {
+    sub := *s
+    sub.resetRun()
+    sub.RecordChanges = false
+    if err := sub.prepareURLs(); err != nil {
+        return nil, err
+    }
+    return &sub, nil
 }
 
-func (s *SitemapOptions) writeSitemapFile(filename string, urls []SitemapURL) error {
-    urlSet := URLSet{
-        Xmlns: "http://www.sitemaps.org/schemas/sitemap/0.9",
-        URLs:  urls,
+// checkBaseSitemapURL ensures sitemap locs in an index can be made absolute.
+func checkBaseSitemapURL(baseSitemapURL string) error {
+    if u, err := url.Parse(baseSitemapURL); err != nil || !u.IsAbs() || u.Host == "" {
+        return fmt.Errorf("baseSitemapURL must be an absolute URL when writing a sitemap index, got '%s'", baseSitemapURL)
     }
+    return nil
+}
 
-    data, err := xml.MarshalIndent(urlSet, "", "  ")
+// prepareURL resolves the loc of a URL about to be written and fills in
+// computed fields.
+func (s *SitemapOptions) prepareURL(u *SitemapURL) error {
+    if err := checkUTF8(*u); err != nil {
+        return err
+    }
+    fullURL, err := s.resolveURL(u.Loc)
     if err != nil {
         return err
     }
-
-    // Add XML header and stylesheet with correct URL
-    buffer := bytes.NewBufferString(xml.Header)
-    buffer.WriteString(fmt.Sprintf(`<?xml-stylesheet type="text/xsl" href="%s"?>`+"\n", s.Stylesheet))
-    buffer.Write(data)
-
-    filePath := path.Join(s.Dir, filename)
-    return os.WriteFile(filePath, buffer.Bytes(), 0644)
-}
-
-func (s *SitemapOptions) writeSitemapIndex(baseSitemapURL string) error {
-    index := SitemapIndex{
-        Xmlns: "http://www.sitemaps.org/schemas/sitemap/0.9",
+    s.recordChange(u.Loc, "loc", u.Loc, fullURL, "loc resolved against BaseURL")
+    canonical, err := s.canonicalHost(fullURL)
+    if err != nil {
+        return err
     }
-
-    fileCount := (len(s.URLs) + s.MaxURLs - 1) / s.MaxURLs
-    for i := 0; i < fileCount; i++ {
-        sitemapName := fmt.Sprintf("sitemap_%d.xml", i+1)
-        start := i * s.MaxURLs
-        end := start + s.MaxURLs
-        if end > len(s.URLs) {
-            end = len(s.URLs)
-        }
-        urlsSlice := s.URLs[start:end]
-        err := s.writeSitemapFile(sitemapName, urlsSlice)
-        if err != nil {
+    s.recordChange(fullURL, "loc", fullURL, canonical, "host rewritten to CanonicalHost")
+    u.Loc = canonical
+    host := hostOf(fullURL)
+    if u.AMPURL != "" {
+        if u.AMPURL, err = s.resolveLink(u.AMPURL, host); err != nil {
             return err
         }
-        sitemapURL, err := s.resolveSitemapURL(baseSitemapURL, sitemapName)
-        if err != nil {
-            return err
+    }
+    if len(u.Alternates) > 0 {
+        // Copied to leave the caller's slice untouched
+        alternates := make([]Alternate, 0, len(u.Alternates))
+        seen := map[string]bool{}
+        for i, alternate := range u.Alternates {
+            if alternate.Hreflang == "" {
+                return fmt.Errorf("alternate %d of '%s' has no hreflang", i+1, u.Loc)
+            }
+            // Language and region codes are case-insensitive
+            hreflang := strings.ToLower(alternate.Hreflang)
+            if seen[hreflang] {
+                if s.DuplicateHreflang == RejectDuplicateHreflang {
+                    return fmt.Errorf("alternate %d of '%s' repeats hreflang '%s'", i+1, u.Loc, alternate.Hreflang)
+                }
+                s.warn("alternate %d of '%s' dropped, repeating hreflang '%s'", i+1, u.Loc, alternate.Hreflang)
+                continue
+            }
+            seen[hreflang] = true
+            if alternate.Href, err = s.resolveLink(alternate.Href, host); err != nil {
+                return err
+            }
+            alternates = append(alternates, alternate)
         }
-        index.Sitemaps = append(index.Sitemaps, Sitemap{
-            Loc:     sitemapURL,
-            LastMod: time.Now().UTC().Format("2006-01-02"),
-        })
+        u.Alternates = alternates
     }
-
-    data, err := xml.MarshalIndent(index, "", "  ")
-    if err != nil {
-        return err
+    if len(u.Images) > 0 {
+        // Copied to leave the caller's slice untouched
+        u.Images = append([]SitemapImage(nil), u.Images...)
+        for i := range u.Images {
+            if strings.TrimSpace(u.Images[i].Loc) == "" {
+                return fmt.Errorf("image %d of '%s' has no loc", i+1, u.Loc)
+            }
+            if u.Images[i].Loc, err = s.resolveLink(u.Images[i].Loc, host); err != nil {
+                return err
+            }
+        }
     }
-
-    // Add XML header and stylesheet with correct URL
-    buffer := bytes.NewBufferString(xml.Header)
-    buffer.WriteString(fmt.Sprintf(`<?xml-stylesheet type="text/xsl" href="%s"?>`+"\n", s.Stylesheet))
-    buffer.Write(data)
-
-    filePath := path.Join(s.Dir, "sitemap_index.xml")
-    return os.WriteFile(filePath, buffer.Bytes(), 0644)
+    if s.PriorityDecay != nil && u.Priority == "" {
+        u.Priority = s.decayedPriority(u.LastMod)
+        s.recordChange(u.Loc, "priority", "", u.Priority, "priority computed by PriorityDecay")
+    }
+    if s.PriorityFloor > 0 && u.Priority != "" {
+        value, err := strconv.ParseFloat(u.Priority, 64)
+        if err == nil && value < s.PriorityFloor {
+            s.recordChange(u.Loc, "priority", u.Priority, "", "priority below PriorityFloor omitted")
+            u.Priority = ""
+        }
+    }
+    if s.EmitDefaultPriority && u.Priority == "" {
+        u.Priority = defaultPriority
+        s.recordChange(u.Loc, "priority", "", u.Priority, "default priority emitted")
+    }
+    return nil
 }
 
-// validateXMLFile validates the given XML file against the sitemap XSD.
-// If isIndex is true, validates against the sitemap index XSD.
-func (s *SitemapOptions) validateXMLFile(filePath string, isIndex bool) error {
-    data, err := os.ReadFile(filePath)
-    if err != nil {
-        return fmt.Errorf("failed to read XML file for validation: %v", err)
-    }
+// defaultPriority is the priority crawlers assume when none is given.
+const defaultPriority = "0.5"
 
-    schemaData := sitemapXSD
-    if isIndex {
-        schemaData = sitemapIndexXSD
+// canonicalHost rewrites the scheme and host of a resolved loc to
+// CanonicalHost, when set.
+func (s *SitemapOptions) canonicalHost(loc string) (string, error) {
+    if s.CanonicalHost == "" {
+        return loc, nil
     }
-
-    // Parse the schema
-    schema, err := xsd.Parse([]byte(schemaData))
+    canonical, err := url.Parse(s.CanonicalHost)
+    if err != nil || canonical.Scheme == "" || canonical.Host == "" {
+        return "", fmt.Errorf("CanonicalHost must be an absolute URL like https://www.example.com, got '%s'", s.CanonicalHost)
+    }
+    parsed, err := url.Parse(loc)
     if err != nil {
-        return fmt.Errorf("failed to parse schema: %v", err)
+        return "", err
     }
-    defer schema.Free()
+    parsed.Scheme = canonical.Scheme
+    parsed.Host = canonical.Host
+    parsed.User = nil
+    return parsed.String(), nil
+}
 
-    // Parse the XML document
-    doc, err := libxml2.Parse(data)
-    if err != nil {
-        return fmt.Errorf("failed to parse XML: %v", err)
+// resolveLink resolves a URL linked from a loc on host, rewriting it to
+// CanonicalHost when it is on the same host.
+func (s *SitemapOptions) resolveLink(link, host string) (string, error) {
+    resolved, err := s.resolveURL(link)
+    if err != nil || hostOf(resolved) != host {
+        return resolved, err
     }
-    defer doc.Free()
+    return s.canonicalHost(resolved)
+}
 
-    // Validate the XML against the schema
-    if err := schema.Validate(doc); err != nil {
-        return fmt.Errorf("XML validation against schema failed: %v", err)
+// hostOf returns the host of an absolute URL, or "" when it has none.
+func hostOf(rawURL string) string {
+    parsed, err := url.Parse(rawURL)
+    if err != nil {
+        return ""
     }
-    return nil
+    return parsed.Host
 }
 
-func (s *SitemapOptions) validateSitemapIndexAndFiles() error {
+// transformURL runs Transforms over a prepared URL and reports whether the
+// result should be written.
+func (s *SitemapOptions) transformURL(u SitemapURL) (SitemapURL, bool) {
+    original := u
+    for _, transform := range s.Transforms {
+        var ok bool
+        if u, ok = transform(u); !ok {
+            return u, false
+        }
+    }
+    s.recordChange(original.Loc, "loc", original.Loc, u.Loc, "rewritten by Transforms")
+    s.recordChange(u.Loc, "lastmod", original.LastMod, u.LastMod, "rewritten by Transforms")
+    s.recordChange(u.Loc, "priority", original.Priority, u.Priority, "rewritten by Transforms")
+    return u, s.keepURL(u)
+}
+
+// keepURL reports whether a transformed URL should be written.
+func (s *SitemapOptions) keepURL(u SitemapURL) bool {
+    if s.MaxQueryParams > 0 {
+        parsed, err := url.Parse(u.Loc)
+        if err != nil {
+            return true
+        }
+        if count := countQueryParams(parsed.RawQuery); count > s.MaxQueryParams {
+            s.warn("'%s' dropped, its %d query parameters exceed MaxQueryParams %d", u.Loc, count, s.MaxQueryParams)
+            return false
+        }
+    }
+    return true
+}
+
+// countQueryParams counts the key/value pairs of a raw query string.
+func countQueryParams(rawQuery string) int {
+    count := 0
+    for _, pair := range strings.Split(rawQuery, "&") {
+        if pair != "" {
+            count++
+        }
+    }
+    return count
+}
+
+// decayedPriority applies PriorityDecay to the age of the given lastmod,
+// measured in whole days against today's date.
+func (s *SitemapOptions) decayedPriority(lastMod string) string {
+    var age time.Duration
+    if timeLastMod, err := lastModDate(lastMod); err == nil {
+        today, _ := time.Parse("2006-01-02", s.today())
+        age = today.Sub(timeLastMod)
+    }
+    priority := s.PriorityDecay(age)
+    if priority < 0 {
+        priority = 0
+    } else if priority > 1 {
+        priority = 1
+    }
+    return strconv.FormatFloat(priority, 'f', 1, 64)
+}
+
+func (s *SitemapOptions) resolveURL(loc string) (string, error) {
+    // Harvested locs often carry surrounding whitespace or newlines
+    loc = strings.TrimSpace(loc)
+    if strings.IndexFunc(loc, unicode.IsControl) >= 0 {
+        return "", fmt.Errorf("loc %q contains control characters", loc)
+    }
+
+    base, err := url.Parse(s.BaseURL)
+    if err != nil {
+        return "", err
+    }
+    ref, err := url.Parse(loc)
+    if err != nil {
+        return "", err
+    }
+    resolved := base.ResolveReference(ref)
+    // Hosts are case-insensitive, lowercased so the same page always gets
+    // the same loc. Scheme parsing already lowercases the scheme.
+    base.Host = strings.ToLower(base.Host)
+    resolved.Host = strings.ToLower(resolved.Host)
+    if s.ForceBasePath && ref.IsAbs() && resolved.Host == base.Host {
+        prefix := strings.TrimRight(base.Path, "/")
+        if prefix != "" && resolved.Path != prefix && !strings.HasPrefix(resolved.Path, prefix+"/") {
+            resolved.Path = prefix + resolved.Path
+            resolved.RawPath = ""
+        }
+    }
+    return resolved.String(), nil
+}
+
+// checkUTF8 rejects URLs with invalid UTF-8 in any of their fields, e.g.
+// from a mis-decoded crawl. encoding/xml would silently replace such bytes,
+// and some parsers choke on them.
+func checkUTF8(u SitemapURL) error {
+    type field struct{ name, value string }
+    fields := []field{
+        {"loc", u.Loc},
+        {"lastmod", u.LastMod},
+        {"changefreq", u.ChangeFreq},
+        {"priority", u.Priority},
+        {"AMPURL", u.AMPURL},
+    }
+    for _, alternate := range u.Alternates {
+        fields = append(fields,
+            field{"alternate hreflang", alternate.Hreflang},
+            field{"alternate href", alternate.Href})
+    }
+    for _, image := range u.Images {
+        fields = append(fields,
+            field{"image loc", image.Loc},
+            field{"image caption", image.Caption},
+            field{"image title", image.Title})
+    }
+    for _, field := range fields {
+        if !utf8.ValidString(field.value) {
+            return fmt.Errorf("%s %q contains invalid UTF-8", field.name, field.value)
+        }
+    }
+    return checkExtensionUTF8(u.Loc, u.Extensions)
+}
+
+// checkExtensionUTF8 rejects extensions of the URL at loc with invalid
+// UTF-8 in their names, attributes or values.
+func checkExtensionUTF8(loc string, exts []Extension) error {
+    for _, ext := range exts {
+        values := []string{ext.XMLName.Space, ext.XMLName.Local, ext.Value}
+        for _, attr := range ext.Attrs {
+            values = append(values, attr.Name.Space, attr.Name.Local, attr.Value)
+        }
+        for _, value := range values {
+            if !utf8.ValidString(value) {
+                return fmt.Errorf("extension <%s> of '%s' contains invalid UTF-8 %q", ext.XMLName.Local, loc, value)
+            }
+        }
+        if err := checkExtensionUTF8(loc, ext.Children); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func (s *SitemapOptions) resolveSitemapURL(baseSitemapURL, sitemapName string) (string, error) {
+    base, err := url.Parse(strings.TrimRight(baseSitemapURL, "/") + "/")
+    if err != nil {
+        return "", err
+    }
+    ref, err := url.Parse(sitemapName)
+    if err != nil {
+        return "", err
+    }
+    return base.ResolveReference(ref).String(), nil
+}
+
+// shardName returns the filename of the n-th (1-based) sitemap shard.
+func shardName(n int) string {
+    return fmt.Sprintf("sitemap_%d.xml", n)
+}
+
+// ShardName returns the filename relative to Dir that Write gives the n-th
+// (1-based) shard of a group holding urls, so the names can be predicted
+// and persisted without writing. The group is "" with the Flat layout and
+// the lastmod year with ByYear. ShardFilename, when set, takes precedence
+// and is passed n and urls, n then counting shards across all groups as it
+// does during Write. Gzip adds the .gz suffix, unless GzipKeepExtension is
+// set.
+func (s *SitemapOptions) ShardName(group string, n int, urls []SitemapURL) string {
+    return s.finalShardName(path.Join(group, shardName(n)), n, urls)
+}
+
+// finalShardName applies ShardFilename and the gzip suffix to name, the
+// default name of the n-th shard holding urls.
+func (s *SitemapOptions) finalShardName(name string, n int, urls []SitemapURL) string {
+    if s.ShardFilename != nil {
+        name = s.ShardFilename(n, urls)
+    }
+    return s.gzipName(name, s.Gzip)
+}
+
+// gzipName returns name with the .gz suffix of compressed files when
+// compress is set, unless GzipKeepExtension is.
+func (s *SitemapOptions) gzipName(name string, compress bool) string {
+    if compress && !s.GzipKeepExtension && !strings.HasSuffix(name, ".gz") {
+        return name + ".gz"
+    }
+    return name
+}
+
+// singleName returns the filename of a sitemap written without an index.
+func (s *SitemapOptions) singleName() string {
+    return s.gzipName("sitemap.xml", s.Gzip)
+}
+
+// indexName returns the filename of the sitemap index.
+func (s *SitemapOptions) indexName() string {
+    return s.gzipName("sitemap_index.xml", s.GzipIndex)
+}
+
+// needsIndex reports whether Write produces a sitemap index rather than a
+// single sitemap file.
+func (s *SitemapOptions) needsIndex() bool {
+    if s.DirLayout == ByYear {
+        return true
+    }
+    threshold := s.indexThreshold()
+    if s.SplitMode == Adaptive {
+        threshold = s.IndexThreshold
+    }
+    // URLs under the threshold still need an index beyond MaxFileSize
+    return (threshold > 0 && len(s.prepared) > threshold) || len(s.shards()) > 1
+}
+
+// indexThreshold returns the URL count above which an index is written.
+func (s *SitemapOptions) indexThreshold() int {
+    if s.IndexThreshold <= 0 || s.IndexThreshold > s.MaxURLs {
+        return s.MaxURLs
+    }
+    return s.IndexThreshold
+}
+
+// shards splits the prepared URLs into the sitemap files referenced by the
+// index. Packing marshals every URL, so the split is computed once per run
+// and copies of it are returned.
+func (s *SitemapOptions) shards() []sitemapShard {
+    if s.split == nil {
+        s.split = s.splitShards()
+    }
+    return append([]sitemapShard(nil), s.split...)
+}
+
+// splitShards computes the shards returned by shards.
+func (s *SitemapOptions) splitShards() []sitemapShard {
+    var shards []sitemapShard
+    if s.DirLayout == ByYear {
+        shards = s.yearShards()
+    } else {
+        shards = s.splitURLs("", s.prepared)
+    }
+    for i := range shards {
+        shards[i].name = s.finalShardName(shards[i].name, i+1, shards[i].urls)
+    }
+    return shards
+}
+
+// checkShardName ensures a shard filename from ShardFilename is a clean
+// relative path inside Dir that doesn't collide with the other files.
+func (s *SitemapOptions) checkShardName(name string) error {
+    switch {
+    case name == "" || path.Clean(name) != name || path.IsAbs(name) ||
+        name == ".." || strings.HasPrefix(name, "../"):
+        return fmt.Errorf("shard filename '%s' must be a clean path relative to Dir", name)
+    case strings.ContainsAny(name, "\\:*?\"<>|") || strings.IndexFunc(name, unicode.IsControl) >= 0:
+        return fmt.Errorf("shard filename '%s' contains characters unsafe in filenames", name)
+    case name == "sitemap_index.xml" || name == s.indexName() || name == s.Stylesheet:
+        return fmt.Errorf("shard filename '%s' collides with a generated file", name)
+    }
+    return nil
+}
+
+// splitURLs splits urls into shards inside the dir prefix as SplitMode
+// requires.
+func (s *SitemapOptions) splitURLs(prefix string, urls []SitemapURL) []sitemapShard {
+    if s.SplitMode == Adaptive {
+        return s.packShards(prefix, urls, protocolMaxURLs)
+    }
+    return s.packShards(prefix, urls, s.MaxURLs)
+}
+
+// packShards packs urls into shards by their encoded size, filling each up
+// to MaxFileSize without exceeding it or maxURLs. The size of a shard is
+// bounded by the size of its empty document plus what each URL adds to it
+// on its own.
+func (s *SitemapOptions) packShards(prefix string, urls []SitemapURL, maxURLs int) []sitemapShard {
+    // The file's directory only matters for the stylesheet reference
+    name := path.Join(prefix, "sitemap.xml")
+    empty, _ := s.marshalURLSet(name, nil)
+
+    var shards []sitemapShard
+    start, size := 0, len(empty)
+    for i := range urls {
+        cost := s.urlCost(name, len(empty), urls[i])
+        if i > start && (s.overFileSize(size+cost) || i-start == maxURLs) {
+            shards = append(shards, sitemapShard{name: path.Join(prefix, shardName(len(shards)+1)), urls: urls[start:i]})
+            start, size = i, len(empty)
+        }
+        size += cost
+    }
+    if start < len(urls) {
+        shards = append(shards, sitemapShard{name: path.Join(prefix, shardName(len(shards)+1)), urls: urls[start:]})
+    }
+    return shards
+}
+
+// overFileSize reports whether a file of the given size exceeds
+// MaxFileSize. Zero or less means no limit.
+func (s *SitemapOptions) overFileSize(size int) bool {
+    return s.MaxFileSize > 0 && size > s.MaxFileSize
+}
+
+// urlCost returns the bytes u adds to the sitemap file name, whose empty
+// document takes emptySize bytes. A URL failing to encode costs the whole
+// MaxFileSize so that it gets a shard of its own, which fails on write.
+func (s *SitemapOptions) urlCost(name string, emptySize int, u SitemapURL) int {
+    single, err := s.marshalURLSet(name, []SitemapURL{u})
+    if err != nil {
+        return s.MaxFileSize
+    }
+    return len(single) - emptySize
+}
+
+// yearShards groups URLs by lastmod year in ascending order and splits
+// each year into its own shards.
+func (s *SitemapOptions) yearShards() []sitemapShard {
+    byYear := map[string][]SitemapURL{}
+    for _, u := range s.prepared {
+        year := s.today()[:4]
+        if timeLastMod, err := lastModDate(u.LastMod); err == nil {
+            year = timeLastMod.Format("2006")
+        }
+        byYear[year] = append(byYear[year], u)
+    }
+
+    years := make([]string, 0, len(byYear))
+    for year := range byYear {
+        years = append(years, year)
+    }
+    sort.Strings(years)
+
+    var shards []sitemapShard
+    for _, year := range years {
+        shards = append(shards, s.splitURLs(year, byYear[year])...)
+    }
+    return shards
+}
+
+// verifySplit checks that shards partition urls: every URL is in exactly as
+// many shards as it appears in urls, and no shard holds anything else.
+func verifySplit(urls []SitemapURL, shards []sitemapShard) error {
+    counts := map[string]int{}
+    for _, u := range urls {
+        counts[u.Loc]++
+    }
+    var extra []string
+    for _, shard := range shards {
+        for _, u := range shard.urls {
+            if counts[u.Loc] == 0 {
+                extra = append(extra, fmt.Sprintf("'%s' in %s", u.Loc, shard.name))
+                continue
+            }
+            counts[u.Loc]--
+        }
+    }
+    var missing []string
+    for _, u := range urls {
+        if counts[u.Loc] > 0 {
+            missing = append(missing, fmt.Sprintf("'%s'", u.Loc))
+            counts[u.Loc]--
+        }
+    }
+    if len(extra) == 0 && len(missing) == 0 {
+        return nil
+    }
+    var problems []string
+    if len(missing) > 0 {
+        problems = append(problems, "missing "+strings.Join(missing, ", "))
+    }
+    if len(extra) > 0 {
+        problems = append(problems, "unexpected or repeated "+strings.Join(extra, ", "))
+    }
+    return fmt.Errorf("shards don't partition the URLs: %s", strings.Join(problems, "; "))
+}
+
+// checkShardUniqueness reports every loc that appears in more than one shard,
+// along with the names of the shards it appears in.
+func checkShardUniqueness(shards []sitemapShard) error {
+    seen := map[string][]string{}
+    var locs []string
+    for _, shard := range shards {
+        for _, u := range shard.urls {
+            names := seen[u.Loc]
+            if len(names) > 0 && names[len(names)-1] == shard.name {
+                continue
+            }
+            if len(names) == 1 {
+                locs = append(locs, u.Loc)
+            }
+            seen[u.Loc] = append(names, shard.name)
+        }
+    }
+    if len(locs) == 0 {
+        return nil
+    }
+
+    duplicates := make([]string, len(locs))
+    for i, loc := range locs {
+        duplicates[i] = fmt.Sprintf("'%s' in %s", loc, strings.Join(seen[loc], ", "))
+    }
+    return fmt.Errorf("duplicate locs across shards: %s", strings.Join(duplicates, "; "))
+}
+
+// ShardForLoc reports which sitemap file the given loc will be written to.
+// The loc is resolved against BaseURL and CanonicalHost before being looked
+// up in a copy of URLs prepared as Write prepares them, so Transforms and
+// the options dropping or reordering URLs are taken into account.
+func (s *SitemapOptions) ShardForLoc(loc string) (filename string, found bool) {
+    target, err := s.resolveURL(loc)
+    if err != nil {
+        return "", false
+    }
+    if target, err = s.canonicalHost(target); err != nil {
+        return "", false
+    }
+
+    sub, err := s.preparedCopy()
+    if err != nil {
+        return "", false
+    }
+    if !sub.needsIndex() {
+        for _, u := range sub.prepared {
+            if u.Loc == target {
+                return sub.singleName(), true
+            }
+        }
+        return "", false
+    }
+    for _, shard := range sub.shards() {
+        for _, u := range shard.urls {
+            if u.Loc == target {
+                return shard.name, true
+            }
+        }
+    }
+    return "", false
+}
+
+// emptyElementPattern matches an element with no content, e.g. <a b="c"></a>.
+var emptyElementPattern = regexp.MustCompile(`<([A-Za-z_][-\w.:]*)([^<>]*)></([A-Za-z_][-\w.:]*)>`)
+
+// selfCloseEmptyElements rewrites empty element pairs in marshaled XML into
+// their self-closing form.
+func selfCloseEmptyElements(data []byte) []byte {
+    return emptyElementPattern.ReplaceAllFunc(data, func(match []byte) []byte {
+        parts := emptyElementPattern.FindSubmatch(match)
+        if !bytes.Equal(parts[1], parts[3]) {
+            return match
+        }
+        return append(append(append([]byte("<"), parts[1]...), parts[2]...), "/>"...)
+    })
+}
+
+// prolog returns a buffer holding the XML header, the generator tag if set
+// and, when enabled, the stylesheet reference for the given file, relative
+// to its own directory.
+func (s *SitemapOptions) prolog(filename string) *bytes.Buffer {
+    buffer := bytes.NewBufferString(xml.Header)
+    if s.GeneratorTag != "" {
+        buffer.WriteString("<?generator " + s.GeneratorTag + "?>\n")
+    }
+    if s.IncludeStylesheet {
+        href := strings.Repeat("../", strings.Count(filename, "/")) + s.Stylesheet
+        buffer.WriteString(fmt.Sprintf(`<?xml-stylesheet type="text/xsl" href="%s"?>`+"\n", href))
+    }
+    return buffer
+}
+
+// document assembles a complete file: the XML declaration, the optional
+// generator tag and stylesheet reference and the root element, each on its
+// own line, with a single trailing newline.
+func (s *SitemapOptions) document(filename string, root []byte) []byte {
+    buffer := s.prolog(filename)
+    buffer.Write(bytes.TrimSpace(root))
+    buffer.WriteByte('\n')
+    return buffer.Bytes()
+}
+
+// marshalXML encodes a root element, indented unless Compact is set.
+func (s *SitemapOptions) marshalXML(v interface{}) ([]byte, error) {
+    // Every document's root goes through here ahead of its prolog
+    if strings.Contains(s.GeneratorTag, "?>") {
+        return nil, fmt.Errorf("GeneratorTag '%s' must not contain '?>'", s.GeneratorTag)
+    }
+    if s.Compact {
+        return xml.Marshal(v)
+    }
+    return xml.MarshalIndent(v, "", "  ")
+}
+
+func (s *SitemapOptions) writeStylesheet() error {
+    return s.writeFile(s.Stylesheet, []byte(sitemapXSL), 0, false)
+}
+
+// writeFile writes a generated file into Dir and records its metadata.
+// The file is gzipped when compress is set or it is named *.gz.
+func (s *SitemapOptions) writeFile(filename string, data []byte, urlCount int, compress bool) error {
+    defer addSince(&s.stats.WriteDuration, time.Now())
+
+    data, err := encodeFile(filename, data, compress)
+    if err != nil {
+        return err
+    }
+
+    filePath := path.Join(s.Dir, filename)
+    if err := s.fs().MkdirAll(path.Dir(filePath), 0755); err != nil {
+        return err
+    }
+    if err := s.fs().WriteFile(filePath, data, 0644); err != nil {
+        return err
+    }
+    if s.BytesWritten != nil {
+        s.BytesWritten(int64(len(data)))
+    }
+    s.files = append(s.files, FileInfo{
+        Name:     filename,
+        Size:     len(data),
+        URLCount: urlCount,
+        Headers:  s.fileHeaders(filename),
+    })
+    return nil
+}
+
+// encodeFile returns data as it is written to filename: gzipped when
+// compress is set or the name ends in .gz.
+func encodeFile(filename string, data []byte, compress bool) ([]byte, error) {
+    if !compress && !strings.HasSuffix(filename, ".gz") {
+        return data, nil
+    }
+    compressed, err := gzipBytes(data)
+    if err != nil {
+        return nil, fmt.Errorf("failed to compress '%s': %v", filename, err)
+    }
+    return compressed, nil
+}
+
+// totalBytes returns the size of the files written so far by the run.
+func (s *SitemapOptions) totalBytes() int {
+    total := 0
+    for _, file := range s.files {
+        total += file.Size
+    }
+    return total
+}
+
+func (s *SitemapOptions) writeSitemapFile(filename string, urls []SitemapURL) error {
+    data, err := s.marshalURLSet(filename, urls)
+    if err != nil {
+        return err
+    }
+    if err := s.writeFile(filename, data, len(urls), s.Gzip); err != nil {
+        return err
+    }
+    s.countExtensions(urls)
+    if s.RoundTripVerify {
+        return s.verifyRoundTrip(filename, urls)
+    }
+    return nil
+}
+
+// defaultNamespaceOrder is the canonical order of the well-known extension
+// namespace declarations.
+var defaultNamespaceOrder = []string{"image", "video", "news", "xhtml"}
+
+// urlSetAttrs returns the given namespace declarations along with
+// URLSetAttrs, which take precedence, as XML attributes in NamespaceOrder
+// and then by name.
+func (s *SitemapOptions) urlSetAttrs(declarations map[string]string) []xml.Attr {
+    values := map[string]string{}
+    for name, value := range declarations {
+        values[name] = value
+    }
+    for name, value := range s.URLSetAttrs {
+        if name != "xmlns" {
+            values[name] = value
+        }
+    }
+
+    names := make([]string, 0, len(values))
+    for name := range values {
+        names = append(names, name)
+    }
+    order := s.NamespaceOrder
+    if order == nil {
+        order = defaultNamespaceOrder
+    }
+    rank := map[string]int{}
+    for i, prefix := range order {
+        rank["xmlns:"+prefix] = i - len(order)
+    }
+    sort.Slice(names, func(i, j int) bool {
+        // Unlisted names rank 0, after every listed one
+        if rank[names[i]] != rank[names[j]] {
+            return rank[names[i]] < rank[names[j]]
+        }
+        return names[i] < names[j]
+    })
+
+    attrs := make([]xml.Attr, len(names))
+    for i, name := range names {
+        attrs[i] = xml.Attr{Name: xml.Name{Local: name}, Value: values[name]}
+    }
+    return attrs
+}
+
+// marshalURLSet renders the complete sitemap document for the given file.
+func (s *SitemapOptions) marshalURLSet(filename string, urls []SitemapURL) ([]byte, error) {
+    defer addSince(&s.stats.MarshalDuration, time.Now())
+
+    urls, declarations := prefixExtensions(withFieldExtensions(urls))
+    urlSet := URLSet{
+        Xmlns: "http://www.sitemaps.org/schemas/sitemap/0.9",
+        Attrs: s.urlSetAttrs(declarations),
+        URLs:  urls,
+    }
+
+    var root interface{} = urlSet
+    if s.EmitEmptyFields {
+        root = urlSet.explicit()
+    }
+    data, err := s.marshalXML(root)
+    if err != nil {
+        return nil, err
+    }
+    if s.SelfClosingEmpty {
+        data = selfCloseEmptyElements(data)
+    }
+
+    return s.document(filename, data), nil
+}
+
+func (s *SitemapOptions) writeSitemapIndex(baseSitemapURL string) error {
+    var entries []indexEntry
+    shards := s.shards()
+    if s.VerifySplit {
+        if err := verifySplit(s.prepared, shards); err != nil {
+            return err
+        }
+    }
+    names := map[string]bool{}
+    for _, shard := range shards {
+        if err := s.checkShardName(shard.name); err != nil {
+            return err
+        }
+        if names[shard.name] {
+            return fmt.Errorf("shard filename '%s' is used by several shards", shard.name)
+        }
+        names[shard.name] = true
+    }
+
+    // Shards are independent unless MaxTotalBytes makes each depend on the
+    // size of the previous ones
+    if s.MaxTotalBytes == 0 {
+        entries = make([]indexEntry, len(shards))
+        err := s.forEachShard(len(shards), func(sub *SitemapOptions, i int) error {
+            data, err := sub.marshalURLSet(shards[i].name, shards[i].urls)
+            if err != nil {
+                return err
+            }
+            entries[i], err = sub.writeShard(baseSitemapURL, shards[i], data)
+            return err
+        })
+        if err != nil {
+            return err
+        }
+        return s.writeIndexFile(entries, baseSitemapURL)
+    }
+
+    for i, shard := range shards {
+        data, err := s.marshalURLSet(shard.name, shard.urls)
+        if err != nil {
+            return err
+        }
+        // Stop before the shard that would exceed MaxTotalBytes, measured
+        // as written, compressed or not like the files counted so far
+        encoded, err := encodeFile(shard.name, data, s.Gzip)
+        if err != nil {
+            return err
+        }
+        if s.totalBytes()+len(encoded) > s.MaxTotalBytes {
+            if i == 0 {
+                return fmt.Errorf("MaxTotalBytes %d is too small for the first shard of %d bytes", s.MaxTotalBytes, len(encoded))
+            }
+            for _, omitted := range shards[i:] {
+                s.omitted += len(omitted.urls)
+            }
+            break
+        }
+        entry, err := s.writeShard(baseSitemapURL, shard, data)
+        if err != nil {
+            return err
+        }
+        entries = append(entries, entry)
+    }
+    return s.writeIndexFile(entries, baseSitemapURL)
+}
+
+// writeShard writes the marshaled data of a shard and returns its index
+// entry.
+func (s *SitemapOptions) writeShard(baseSitemapURL string, shard sitemapShard, data []byte) (indexEntry, error) {
+    if err := s.writeFile(shard.name, data, len(shard.urls), s.Gzip); err != nil {
+        return indexEntry{}, err
+    }
+    s.countExtensions(shard.urls)
+    if s.RoundTripVerify {
+        if err := s.verifyRoundTrip(shard.name, shard.urls); err != nil {
+            return indexEntry{}, err
+        }
+    }
+    return s.newIndexEntry(baseSitemapURL, shard.name, shard.urls)
+}
+
+// indexEntry is an index entry along with the shard details it is
+// ordered by.
+type indexEntry struct {
+    sitemap  Sitemap
+    lastMod  string // Newest lastmod among the shard's URLs, with LastModDesc
+    urlCount int
+}
+
+// newIndexEntry builds the index entry for a shard written as name.
+func (s *SitemapOptions) newIndexEntry(baseSitemapURL, name string, urls []SitemapURL) (indexEntry, error) {
+    sitemapURL, err := s.resolveSitemapURL(baseSitemapURL, name)
+    if err != nil {
+        return indexEntry{}, err
+    }
+    // Parsing every lastmod is only worth it when ordering by it
+    lastMod := ""
+    if s.IndexOrder == LastModDesc {
+        for _, u := range urls {
+            lastMod = laterLastMod(lastMod, u.LastMod)
+        }
+    }
+    return indexEntry{
+        sitemap: Sitemap{
+            Loc:     sitemapURL,
+            LastMod: s.indexLastMod(urls),
+        },
+        lastMod:  lastMod,
+        urlCount: len(urls),
+    }, nil
+}
+
+// orderIndex returns the index entries' sitemaps in IndexOrder. Only the
+// order of the entries changes, each still references its own shard.
+func (s *SitemapOptions) orderIndex(entries []indexEntry) []Sitemap {
+    ordered := append([]indexEntry(nil), entries...)
+    switch s.IndexOrder {
+    case LastModDesc:
+        sort.SliceStable(ordered, func(i, j int) bool {
+            return newerLastMod(ordered[i].lastMod, ordered[j].lastMod)
+        })
+    case URLCountDesc:
+        sort.SliceStable(ordered, func(i, j int) bool {
+            return ordered[i].urlCount > ordered[j].urlCount
+        })
+    }
+
+    sitemaps := make([]Sitemap, len(ordered))
+    for i, entry := range ordered {
+        sitemaps[i] = entry.sitemap
+    }
+    return sitemaps
+}
+
+// indexLastMod returns the index lastmod for a shard: today, or in
+// Canonical mode the newest lastmod among its URLs.
+func (s *SitemapOptions) indexLastMod(urls []SitemapURL) string {
+    if !s.Canonical {
+        return s.today()
+    }
+    lastMod := ""
+    for _, u := range urls {
+        lastMod = laterLastMod(lastMod, u.LastMod)
+    }
+    return lastMod
+}
+
+// laterLastMod returns the more recent of two lastmod values.
+func laterLastMod(a, b string) string {
+    timeA, errA := parseW3CDate(a)
+    timeB, errB := parseW3CDate(b)
+    switch {
+    case errB != nil:
+        return a
+    case errA != nil || timeB.After(timeA):
+        return b
+    default:
+        return a
+    }
+}
+
+// newerLastMod reports whether lastmod a is strictly later than b. A missing
+// or unparsable lastmod is older than any valid one.
+func newerLastMod(a, b string) bool {
+    timeA, errA := parseW3CDate(a)
+    if errA != nil {
+        return false
+    }
+    timeB, errB := parseW3CDate(b)
+    return errB != nil || timeA.After(timeB)
+}
+
+// writeIndexFile writes the given entries as sitemap_index.xml, adding the
+// entry for the index itself when SelfReferenceIndex is set.
+func (s *SitemapOptions) writeIndexFile(entries []indexEntry, baseSitemapURL string) error {
+    index := SitemapIndex{
+        Xmlns:    "http://www.sitemaps.org/schemas/sitemap/0.9",
+        Sitemaps: s.orderIndex(entries),
+    }
+    if s.SelfReferenceIndex {
+        indexURL, err := s.resolveSitemapURL(baseSitemapURL, s.indexName())
+        if err != nil {
+            return err
+        }
+        lastMod := s.today()
+        if s.Canonical {
+            lastMod = ""
+            for _, sitemap := range index.Sitemaps {
+                lastMod = laterLastMod(lastMod, sitemap.LastMod)
+            }
+        }
+        index.Sitemaps = append(index.Sitemaps, Sitemap{
+            Loc:     indexURL,
+            LastMod: lastMod,
+        })
+    }
+
+    return s.writeIndexDocument(index)
+}
+
+// writeIndexDocument marshals and writes index as sitemap_index.xml, or
+// sitemap_index.xml.gz with GzipIndex.
+func (s *SitemapOptions) writeIndexDocument(index SitemapIndex) error {
+    s.index = &index
+    start := time.Now()
+    data, err := s.marshalXML(index)
+    if err != nil {
+        return err
+    }
+
+    document := s.document(s.indexName(), data)
+    addSince(&s.stats.MarshalDuration, start)
+
+    return s.writeFile(s.indexName(), document, 0, s.GzipIndex)
+}
+
+// validateXMLFile validates the given XML file against the sitemap XSD.
+// If isIndex is true, validates against the sitemap index XSD.
+func (s *SitemapOptions) validateXMLFile(filePath string, isIndex bool) error {
+    defer addSince(&s.stats.ValidateDuration, time.Now())
+
+    if s.skipValidation(filePath) {
+        return nil
+    }
+    raw, err := s.fs().ReadFile(filePath)
+    if err != nil {
+        return fmt.Errorf("failed to read XML file for validation: %v", err)
+    }
+    return s.validateBytes(raw, isIndex)
+}
+
+// skipValidation reports whether the file at filePath goes unvalidated,
+// with Validate unset or through SkipValidationFor.
+func (s *SitemapOptions) skipValidation(filePath string) bool {
+    if !s.Validate {
+        return true
+    }
+    if s.SkipValidationFor != nil {
+        name := strings.TrimPrefix(path.Clean(filePath), path.Clean(s.Dir)+"/")
+        return s.SkipValidationFor(name)
+    }
+    return false
+}
+
+// ValidateBytes validates an in-memory sitemap, or sitemap index if isIndex
+// is true, against the same schemas as Write, without touching the disk.
+// Gzipped data is decompressed first.
+func (s *SitemapOptions) ValidateBytes(data []byte, isIndex bool) error {
+    return s.validateBytes(data, isIndex)
+}
+
+// validateBytes validates raw file content, consulting the validation cache
+// when CacheValidation is set.
+func (s *SitemapOptions) validateBytes(raw []byte, isIndex bool) error {
+    data, err := gunzipIfNeeded(raw)
+    if err != nil {
+        return fmt.Errorf("failed to decompress XML file for validation: %v", err)
+    }
+
+    if !s.CacheValidation || s.Validator != nil {
+        return s.validateXML(data, isIndex)
+    }
+    key := validationCacheKey(data, isIndex, s.SchemaSources)
+    if ok, err := cachedValidation(key); ok {
+        return err
+    }
+    err = s.validateXML(data, isIndex)
+    storeValidation(key, err)
+    return err
+}
+
+// validateXML validates an XML document against the sitemap XSD, or the
+// sitemap index XSD if isIndex is true, or with Validator when set.
+func (s *SitemapOptions) validateXML(data []byte, isIndex bool) error {
+    if s.Validator != nil {
+        return s.Validator(data, isIndex)
+    }
+    return s.validateXSD(data, isIndex)
+}
+
+func (s *SitemapOptions) validateSitemapIndexAndFiles(baseSitemapURL string) error {
     // Validate sitemap index
-    indexFilePath := path.Join(s.Dir, "sitemap_index.xml")
+    indexFilePath := path.Join(s.Dir, s.indexName())
     if err := s.validateXMLFile(indexFilePath, true); err != nil {
         return err
     }
 
     // Read the sitemap index to get the list of sitemaps
-    indexData, err := os.ReadFile(indexFilePath)
+    raw, err := s.fs().ReadFile(indexFilePath)
     if err != nil {
         return fmt.Errorf("failed to read sitemap index for validation: %v", err)
     }
+    indexData, err := gunzipIfNeeded(raw)
+    if err != nil {
+        return fmt.Errorf("failed to decompress sitemap index for validation: %v", err)
+    }
 
     var index SitemapIndex
     if err := xml.Unmarshal(indexData, &index); err != nil {
         return fmt.Errorf("XML unmarshalling failed for sitemap index: %v", err)
     }
 
-    // Validate each sitemap file listed in the index
+    baseURL, err := s.resolveSitemapURL(baseSitemapURL, "")
+    if err != nil {
+        return err
+    }
+
+    // Map each sitemap listed in the index to its file, checking they all
+    // exist before validating any of them
+    files := map[string]string{}
+    var missing []string
     for _, sitemap := range index.Sitemaps {
-        // Extract the filename from the sitemap location
+        // Extract the filename from the sitemap location, keeping any
+        // subdirectory below baseSitemapURL
         sitemapURL, err := url.Parse(sitemap.Loc)
         if err != nil {
             return fmt.Errorf("invalid sitemap URL '%s': %v", sitemap.Loc, err)
         }
         sitemapFile := path.Base(sitemapURL.Path)
-        sitemapFilePath := path.Join(s.Dir, sitemapFile)
+        if strings.HasPrefix(sitemap.Loc, baseURL) {
+            sitemapFile = strings.TrimPrefix(sitemap.Loc, baseURL)
+        }
+        // A self-referencing entry is the index just validated
+        if sitemapFile == s.indexName() {
+            continue
+        }
+        if _, err := s.fs().Stat(path.Join(s.Dir, sitemapFile)); err != nil {
+            missing = append(missing, sitemapFile)
+        }
+        files[sitemap.Loc] = sitemapFile
+    }
+    if len(missing) > 0 {
+        return fmt.Errorf("sitemap index references files missing from '%s': %s", s.Dir, strings.Join(missing, ", "))
+    }
 
-        // Validate the sitemap file
-        if err := s.validateXMLFile(sitemapFilePath, false); err != nil {
-            return err
+    // Validate each sitemap file listed in the index
+    var listed []Sitemap
+    for _, sitemap := range index.Sitemaps {
+        if _, ok := files[sitemap.Loc]; ok {
+            listed = append(listed, sitemap)
         }
     }
-    return nil
+    errs := make([]error, len(listed))
+    err = s.forEachShard(len(listed), func(sub *SitemapOptions, i int) error {
+        errs[i] = sub.validateXMLFile(path.Join(s.Dir, files[listed[i].Loc]), false)
+        if s.DropInvalidShards {
+            return nil
+        }
+        return errs[i]
+    })
+    if err != nil {
+        return err
+    }
+
+    dropped := map[string]string{}
+    valid := 0
+    var lastErr error
+    for i, sitemap := range listed {
+        if errs[i] != nil {
+            s.warn("shard '%s' dropped from the index: %v", files[sitemap.Loc], errs[i])
+            dropped[sitemap.Loc] = files[sitemap.Loc]
+            lastErr = errs[i]
+            continue
+        }
+        valid++
+    }
+    if len(dropped) == 0 {
+        return nil
+    }
+    if s.index == nil {
+        return lastErr
+    }
+    if valid == 0 {
+        return fmt.Errorf("no shard of the index passed validation: %v", lastErr)
+    }
+    return s.dropShards(dropped)
+}
+
+// dropShards rewrites the index without the given shards, keyed by loc,
+// and forgets their files.
+func (s *SitemapOptions) dropShards(dropped map[string]string) error {
+    index := *s.index
+    index.Sitemaps = nil
+    for _, sitemap := range s.index.Sitemaps {
+        if _, ok := dropped[sitemap.Loc]; !ok {
+            index.Sitemaps = append(index.Sitemaps, sitemap)
+        }
+    }
+
+    removed := map[string]bool{s.indexName(): true}
+    for _, file := range dropped {
+        removed[file] = true
+    }
+    files := s.files[:0]
+    for _, file := range s.files {
+        if !removed[file.Name] {
+            files = append(files, file)
+        }
+    }
+    s.files = files
+
+    if err := s.writeIndexDocument(index); err != nil {
+        return err
+    }
+    return s.validateXMLFile(path.Join(s.Dir, s.indexName()), true)
 }
@@ -1,11 +1,15 @@
 package nyxsitemap
 
 import (
+    "bytes"
+    "encoding/xml"
+    "fmt"
     "os"
     "path"
     "strconv"
     "strings"
     "testing"
+    "time"
 )
 
 func TestSitemapGeneration(t *testing.T) {
@@ -82,3 +86,1506 @@ func TestSitemapGeneration(t *testing.T) {
     // Clean up after test
     os.RemoveAll(dir)
 }
+
+func TestShardForLoc(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_shard", "https://www.example.com")
+    sm.MaxURLs = 10
+
+    for i := 0; i < 25; i++ {
+        sm.AddURL(SitemapURL{Loc: "/page/" + strconv.Itoa(i)})
+    }
+
+    cases := map[string]string{
+        "/page/0":                         "sitemap_1.xml",
+        "/page/9":                         "sitemap_1.xml",
+        "https://www.example.com/page/10": "sitemap_2.xml",
+        "/page/24":                        "sitemap_3.xml",
+    }
+    for loc, expected := range cases {
+        filename, found := sm.ShardForLoc(loc)
+        if !found {
+            t.Fatalf("Expected %s to be found", loc)
+        }
+        if filename != expected {
+            t.Fatalf("Expected %s to be in %s, got %s", loc, expected, filename)
+        }
+    }
+
+    if _, found := sm.ShardForLoc("/missing"); found {
+        t.Fatalf("Expected /missing not to be found")
+    }
+
+    // Shards are those of the prepared URLs, before and after a Write
+    sm.Canonical = true
+    sm.MaxQueryParams = 1
+    sm.AddURL(SitemapURL{Loc: "/page/1?a=1&b=2"})
+    files, err := sm.WriteToMemory("https://www.example.com/sitemaps/")
+    if err != nil {
+        t.Fatalf("Error writing sitemaps: %v", err)
+    }
+    for _, loc := range []string{"/page/0", "/page/24", "/page/9"} {
+        for i := 0; i < 2; i++ {
+            filename, found := sm.ShardForLoc(loc)
+            if !found || !strings.Contains(string(files[filename]), "<loc>https://www.example.com"+loc+"</loc>") {
+                t.Fatalf("Expected %s to be reported in the shard holding it, got %s", loc, filename)
+            }
+        }
+    }
+    if _, found := sm.ShardForLoc("/page/1?a=1&b=2"); found {
+        t.Fatalf("Expected a URL dropped by MaxQueryParams not to be found")
+    }
+}
+
+func TestPriorityDecay(t *testing.T) {
+    dir := "./test_sitemaps_decay"
+    os.RemoveAll(dir)
+    defer os.RemoveAll(dir)
+
+    sm := NewSitemapOptions(dir, "https://www.example.com")
+    sm.PriorityDecay = func(age time.Duration) float64 {
+        return 1 - age.Hours()/24/10
+    }
+
+    now := time.Now().UTC()
+    sm.AddURL(SitemapURL{Loc: "/fresh", LastMod: now.Format("2006-01-02")})
+    sm.AddURL(SitemapURL{Loc: "/week", LastMod: now.AddDate(0, 0, -5).Format("2006-01-02")})
+    sm.AddURL(SitemapURL{Loc: "/old", LastMod: now.AddDate(0, 0, -40).Format("2006-01-02")})
+    sm.AddURL(SitemapURL{Loc: "/explicit", LastMod: now.AddDate(0, 0, -40).Format("2006-01-02"), Priority: "0.9"})
+
+    if err := sm.Write("https://www.example.com/"); err != nil {
+        t.Fatalf("Error writing sitemaps: %v", err)
+    }
+
+    expected := []string{"1.0", "0.5", "0.0", "0.9"}
+    for i, priority := range expected {
+        if sm.prepared[i].Priority != priority {
+            t.Fatalf("Expected priority %s for %s, got %s", priority, sm.prepared[i].Loc, sm.prepared[i].Priority)
+        }
+    }
+}
+
+func TestDirLayoutByYear(t *testing.T) {
+    dir := "./test_sitemaps_year"
+    baseSitemapURL := "https://www.example.com/sitemaps/"
+    os.RemoveAll(dir)
+    defer os.RemoveAll(dir)
+
+    sm := NewSitemapOptions(dir, "https://www.example.com")
+    sm.DirLayout = ByYear
+    sm.AddURL(SitemapURL{Loc: "/a", LastMod: "2022-03-01"})
+    sm.AddURL(SitemapURL{Loc: "/b", LastMod: "2023-05-10"})
+    sm.AddURL(SitemapURL{Loc: "/c", LastMod: "2022-11-20"})
+
+    if err := sm.Write(baseSitemapURL); err != nil {
+        t.Fatalf("Error writing sitemaps: %v", err)
+    }
+
+    for _, name := range []string{"2022/sitemap_1.xml", "2023/sitemap_1.xml"} {
+        data, err := os.ReadFile(path.Join(dir, name))
+        if err != nil {
+            t.Fatalf("Expected %s to be written: %v", name, err)
+        }
+        if !strings.Contains(string(data), `href="../sitemap.xsl"`) {
+            t.Fatalf("Expected %s to reference the stylesheet in the parent directory", name)
+        }
+    }
+
+    data, err := os.ReadFile(path.Join(dir, "sitemap_index.xml"))
+    if err != nil {
+        t.Fatalf("Error reading sitemap index: %v", err)
+    }
+    for _, loc := range []string{baseSitemapURL + "2022/sitemap_1.xml", baseSitemapURL + "2023/sitemap_1.xml"} {
+        if !strings.Contains(string(data), "<loc>"+loc+"</loc>") {
+            t.Fatalf("Sitemap index does not reference %s", loc)
+        }
+    }
+
+    if filename, _ := sm.ShardForLoc("/c"); filename != "2022/sitemap_1.xml" {
+        t.Fatalf("Expected /c in 2022/sitemap_1.xml, got %s", filename)
+    }
+}
+
+func TestWriteIndexRequiresBaseSitemapURL(t *testing.T) {
+    dir := "./test_sitemaps_nobase"
+    os.RemoveAll(dir)
+    defer os.RemoveAll(dir)
+
+    sm := NewSitemapOptions(dir, "https://www.example.com")
+    sm.MaxURLs = 2
+    for i := 0; i < 3; i++ {
+        sm.AddURL(SitemapURL{Loc: "/page/" + strconv.Itoa(i)})
+    }
+
+    err := sm.Write("")
+    if err == nil {
+        t.Fatalf("Expected an error for an empty baseSitemapURL")
+    }
+    if !strings.Contains(err.Error(), "baseSitemapURL must be an absolute URL") {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+
+    // A single sitemap does not need baseSitemapURL
+    sm.MaxURLs = 10
+    if err := sm.Write(""); err != nil {
+        t.Fatalf("Expected single sitemap to be written, got: %v", err)
+    }
+}
+
+func TestIndexThreshold(t *testing.T) {
+    dir := "./test_sitemaps_threshold"
+    os.RemoveAll(dir)
+    defer os.RemoveAll(dir)
+
+    sm := NewSitemapOptions(dir, "https://www.example.com")
+    sm.MaxURLs = 10
+    sm.IndexThreshold = 3
+    for i := 0; i < 5; i++ {
+        sm.AddURL(SitemapURL{Loc: "/page/" + strconv.Itoa(i)})
+    }
+
+    if err := sm.Write("https://www.example.com/"); err != nil {
+        t.Fatalf("Error writing sitemaps: %v", err)
+    }
+
+    data, err := os.ReadFile(path.Join(dir, "sitemap_index.xml"))
+    if err != nil {
+        t.Fatalf("Expected a sitemap index: %v", err)
+    }
+    if strings.Count(string(data), "<sitemap>") != 1 {
+        t.Fatalf("Expected a single shard in the index, got:\n%s", data)
+    }
+    if _, err := os.Stat(path.Join(dir, "sitemap_2.xml")); !os.IsNotExist(err) {
+        t.Fatalf("Expected no second shard")
+    }
+}
+
+func TestExcludeStylesheet(t *testing.T) {
+    dir := "./test_sitemaps_nostylesheet"
+    os.RemoveAll(dir)
+    defer os.RemoveAll(dir)
+
+    sm := NewSitemapOptions(dir, "https://www.example.com")
+    sm.IncludeStylesheet = false
+    sm.AddURL(SitemapURL{Loc: "/"})
+
+    if err := sm.Write("https://www.example.com/"); err != nil {
+        t.Fatalf("Error writing sitemaps: %v", err)
+    }
+
+    data, err := os.ReadFile(path.Join(dir, "sitemap.xml"))
+    if err != nil {
+        t.Fatalf("Error reading sitemap: %v", err)
+    }
+    if strings.Contains(string(data), "<?xml-stylesheet") {
+        t.Fatalf("Expected no stylesheet reference, got:\n%s", data)
+    }
+    if _, err := os.Stat(path.Join(dir, sm.Stylesheet)); !os.IsNotExist(err) {
+        t.Fatalf("Expected stylesheet file not to be written")
+    }
+}
+
+func TestCheckGlobalUniqueness(t *testing.T) {
+    dir := "./test_sitemaps_unique"
+    os.RemoveAll(dir)
+    defer os.RemoveAll(dir)
+
+    sm := NewSitemapOptions(dir, "https://www.example.com")
+    sm.MaxURLs = 2
+    sm.CheckGlobalUniqueness = true
+    sm.AddURL(SitemapURL{Loc: "/a"})
+    sm.AddURL(SitemapURL{Loc: "/b"})
+    sm.AddURL(SitemapURL{Loc: "https://www.example.com/a"})
+
+    err := sm.Write("https://www.example.com/")
+    if err == nil {
+        t.Fatalf("Expected duplicate loc across shards to be detected")
+    }
+    expected := "'https://www.example.com/a' in sitemap_1.xml, sitemap_2.xml"
+    if !strings.Contains(err.Error(), expected) {
+        t.Fatalf("Expected error to mention %s, got: %v", expected, err)
+    }
+}
+
+func TestSelfCloseEmptyElements(t *testing.T) {
+    input := `<url><loc>https://www.example.com/</loc><mobile:mobile></mobile:mobile><a type="x"></a><b></c></url>`
+
+    expected := `<url><loc>https://www.example.com/</loc><mobile:mobile/><a type="x"/><b></c></url>`
+    if output := string(selfCloseEmptyElements([]byte(input))); output != expected {
+        t.Fatalf("Unexpected self-closing output:\n%s", output)
+    }
+
+    // An alternate is emitted as an empty xhtml:link element
+    sm := NewSitemapOptions("./test_sitemaps_selfclose", "https://www.example.com")
+    sm.AddURL(SitemapURL{Loc: "/", Alternates: []Alternate{{Hreflang: "fr", Href: "/fr/"}}})
+    explicit, err := sm.marshalURLSet("sitemap.xml", sm.URLs)
+    if err != nil {
+        t.Fatalf("Error marshaling sitemap: %v", err)
+    }
+    link := `<xhtml:link rel="alternate" hreflang="fr" href="/fr/"`
+    if !strings.Contains(string(explicit), link+"></xhtml:link>") {
+        t.Fatalf("Expected an explicit empty element without SelfClosingEmpty, got:\n%s", explicit)
+    }
+    sm.SelfClosingEmpty = true
+    selfClosing, err := sm.marshalURLSet("sitemap.xml", sm.URLs)
+    if err != nil {
+        t.Fatalf("Error marshaling sitemap: %v", err)
+    }
+    if !strings.Contains(string(selfClosing), link+"/>") || strings.Contains(string(selfClosing), "></xhtml:link>") {
+        t.Fatalf("Expected a self-closing empty element with SelfClosingEmpty, got:\n%s", selfClosing)
+    }
+}
+
+func TestEmitEmptyFields(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_empty_fields", "https://www.example.com")
+    sm.AddURL(SitemapURL{Loc: "/", LastMod: "2023-10-25"})
+
+    data, err := sm.marshalURLSet("sitemap.xml", sm.URLs)
+    if err != nil {
+        t.Fatalf("Error marshaling sitemap: %v", err)
+    }
+    if strings.Contains(string(data), "<changefreq>") || strings.Contains(string(data), "<priority>") {
+        t.Fatalf("Expected empty fields to be omitted by default, got:\n%s", data)
+    }
+
+    sm.EmitEmptyFields = true
+    data, err = sm.marshalURLSet("sitemap.xml", sm.URLs)
+    if err != nil {
+        t.Fatalf("Error marshaling sitemap: %v", err)
+    }
+    for _, element := range []string{"<lastmod>2023-10-25</lastmod>", "<changefreq></changefreq>", "<priority></priority>"} {
+        if !strings.Contains(string(data), element) {
+            t.Fatalf("Expected %s with EmitEmptyFields, got:\n%s", element, data)
+        }
+    }
+
+    sm.SelfClosingEmpty = true
+    data, err = sm.marshalURLSet("sitemap.xml", sm.URLs)
+    if err != nil {
+        t.Fatalf("Error marshaling sitemap: %v", err)
+    }
+    if !strings.Contains(string(data), "<changefreq/>") || !strings.Contains(string(data), "<priority/>") {
+        t.Fatalf("Expected self-closing empty fields, got:\n%s", data)
+    }
+}
+
+func TestURLSetAttrs(t *testing.T) {
+    dir := "./test_sitemaps_attrs"
+    os.RemoveAll(dir)
+    defer os.RemoveAll(dir)
+
+    sm := NewSitemapOptions(dir, "https://www.example.com")
+    sm.URLSetAttrs = map[string]string{
+        "xmlns:vendor": "https://vendor.example.com/ns",
+        "vendor:build": "42",
+    }
+    sm.AddURL(SitemapURL{Loc: "/"})
+
+    if err := sm.Write("https://www.example.com/"); err != nil {
+        t.Fatalf("Error writing sitemaps: %v", err)
+    }
+
+    data, err := os.ReadFile(path.Join(dir, "sitemap.xml"))
+    if err != nil {
+        t.Fatalf("Error reading sitemap: %v", err)
+    }
+    root := `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9" vendor:build="42" xmlns:vendor="https://vendor.example.com/ns">`
+    if !strings.Contains(string(data), root) {
+        t.Fatalf("Expected root element %s, got:\n%s", root, data)
+    }
+}
+
+func TestDocumentLayout(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_layout", "https://www.example.com")
+    sm.AddURL(SitemapURL{Loc: "https://www.example.com/", LastMod: "2023-10-25"})
+
+    data, err := sm.marshalURLSet("sitemap.xml", sm.URLs)
+    if err != nil {
+        t.Fatalf("Error marshaling sitemap: %v", err)
+    }
+    prolog := "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n" +
+        "<?xml-stylesheet type=\"text/xsl\" href=\"sitemap.xsl\"?>\n" +
+        "<urlset xmlns=\"http://www.sitemaps.org/schemas/sitemap/0.9\">\n"
+    if !strings.HasPrefix(string(data), prolog) {
+        t.Fatalf("Unexpected prolog layout:\n%q", data)
+    }
+    if !strings.HasSuffix(string(data), "</urlset>\n") || strings.HasSuffix(string(data), "\n\n") {
+        t.Fatalf("Expected a single trailing newline after the root element:\n%q", data)
+    }
+
+    sm.IncludeStylesheet = false
+    data, _ = sm.marshalURLSet("sitemap.xml", sm.URLs)
+    if !strings.HasPrefix(string(data), "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<urlset ") {
+        t.Fatalf("Unexpected prolog layout without stylesheet:\n%q", data)
+    }
+
+    // Compact output keeps the stylesheet reference on its own line,
+    // followed by the root element on a single line
+    sm.IncludeStylesheet = true
+    sm.Compact = true
+    data, _ = sm.marshalURLSet("sitemap.xml", sm.URLs)
+    expected := "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n" +
+        "<?xml-stylesheet type=\"text/xsl\" href=\"sitemap.xsl\"?>\n" +
+        "<urlset xmlns=\"http://www.sitemaps.org/schemas/sitemap/0.9\"><url><loc>https://www.example.com/</loc><lastmod>2023-10-25</lastmod></url></urlset>\n"
+    if string(data) != expected {
+        t.Fatalf("Unexpected compact layout:\n%q", data)
+    }
+}
+
+func TestSelfReferenceIndex(t *testing.T) {
+    dir := "./test_sitemaps_selfref"
+    baseSitemapURL := "https://www.example.com/sitemaps/"
+    os.RemoveAll(dir)
+    defer os.RemoveAll(dir)
+
+    sm := NewSitemapOptions(dir, "https://www.example.com")
+    sm.MaxURLs = 2
+    sm.SelfReferenceIndex = true
+    for i := 0; i < 3; i++ {
+        sm.AddURL(SitemapURL{Loc: "/page/" + strconv.Itoa(i)})
+    }
+
+    // Validation walks the index entries and must not recurse into itself
+    if err := sm.Write(baseSitemapURL); err != nil {
+        t.Fatalf("Error writing sitemaps: %v", err)
+    }
+
+    data, err := os.ReadFile(path.Join(dir, "sitemap_index.xml"))
+    if err != nil {
+        t.Fatalf("Error reading sitemap index: %v", err)
+    }
+    if !strings.Contains(string(data), "<loc>"+baseSitemapURL+"sitemap_index.xml</loc>") {
+        t.Fatalf("Expected the index to reference itself, got:\n%s", data)
+    }
+    if strings.Count(string(data), "<sitemap>") != 3 {
+        t.Fatalf("Expected two shards plus the self reference, got:\n%s", data)
+    }
+}
+
+func TestCanonicalOutput(t *testing.T) {
+    baseSitemapURL := "https://www.example.com/sitemaps/"
+    urls := []SitemapURL{
+        {Loc: "/c", LastMod: "2023-01-03"},
+        {Loc: "/a", LastMod: "2023-01-01"},
+        {Loc: "/d", LastMod: "2022-12-31"},
+        {Loc: "/b", LastMod: "2023-01-02"},
+    }
+
+    generate := func(urls []SitemapURL) map[string][]byte {
+        sm := NewSitemapOptions("./test_sitemaps_canonical", "https://www.example.com")
+        sm.MaxURLs = 2
+        sm.Canonical = true
+        sm.URLSetAttrs = map[string]string{"xmlns:b": "https://b.example.com", "xmlns:a": "https://a.example.com"}
+        sm.AddURLs(urls)
+        files, err := sm.WriteToMemory(baseSitemapURL)
+        if err != nil {
+            t.Fatalf("Error writing sitemaps: %v", err)
+        }
+        // Only the written copy is sorted
+        for i, u := range urls {
+            if sm.URLs[i].Loc != u.Loc {
+                t.Fatalf("Expected URLs to keep their order, got %s at %d", sm.URLs[i].Loc, i)
+            }
+        }
+        return files
+    }
+
+    first := generate(urls)
+    second := generate(urls)
+    reversed := make([]SitemapURL, len(urls))
+    for i, u := range urls {
+        reversed[len(urls)-1-i] = u
+    }
+    third := generate(reversed)
+
+    for name, data := range first {
+        if string(second[name]) != string(data) || string(third[name]) != string(data) {
+            t.Fatalf("Expected byte-identical %s across runs", name)
+        }
+    }
+
+    index := string(first["sitemap_index.xml"])
+    for _, entry := range []string{
+        "<loc>" + baseSitemapURL + "sitemap_1.xml</loc>\n    <lastmod>2023-01-02</lastmod>",
+        "<loc>" + baseSitemapURL + "sitemap_2.xml</loc>\n    <lastmod>2023-01-03</lastmod>",
+    } {
+        if !strings.Contains(index, entry) {
+            t.Fatalf("Expected index entry %q, got:\n%s", entry, index)
+        }
+    }
+}
+
+func TestMaxQueryParams(t *testing.T) {
+    dir := "./test_sitemaps_query"
+    os.RemoveAll(dir)
+    defer os.RemoveAll(dir)
+
+    sm := NewSitemapOptions(dir, "https://www.example.com")
+    sm.MaxQueryParams = 2
+    sm.AddURL(SitemapURL{Loc: "/shoes?color=red&size=9"})
+    sm.AddURL(SitemapURL{Loc: "/shoes?color=red&size=9&brand=x&sort=price&page=2"})
+
+    if err := sm.Write("https://www.example.com/"); err != nil {
+        t.Fatalf("Error writing sitemaps: %v", err)
+    }
+
+    data, err := os.ReadFile(path.Join(dir, "sitemap.xml"))
+    if err != nil {
+        t.Fatalf("Error reading sitemap: %v", err)
+    }
+    if !strings.Contains(string(data), "size=9</loc>") {
+        t.Fatalf("Expected URL with 2 params to be kept, got:\n%s", data)
+    }
+    if strings.Contains(string(data), "brand=x") {
+        t.Fatalf("Expected URL with 5 params to be dropped, got:\n%s", data)
+    }
+    warnings := sm.Warnings()
+    if len(warnings) != 1 || !strings.Contains(warnings[0], "brand=x") || !strings.Contains(warnings[0], "MaxQueryParams") {
+        t.Fatalf("Expected a warning for the dropped URL, got %v", warnings)
+    }
+
+    // The URL is only dropped from the written copy
+    sm.MaxQueryParams = 0
+    if err := sm.Write("https://www.example.com/"); err != nil {
+        t.Fatalf("Error writing sitemaps: %v", err)
+    }
+    data, err = os.ReadFile(path.Join(dir, "sitemap.xml"))
+    if err != nil {
+        t.Fatalf("Error reading sitemap: %v", err)
+    }
+    if !strings.Contains(string(data), "brand=x") {
+        t.Fatalf("Expected the URL back without MaxQueryParams, got:\n%s", data)
+    }
+}
+
+func TestFeeds(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_feeds", "https://www.example.com")
+    sm.AddURL(SitemapURL{Loc: "/", LastMod: "2023-10-25"})
+    sm.AddURL(SitemapURL{Loc: "/feed.xml", LastMod: "2023-10-25", FeedURL: true})
+
+    feeds := sm.Feeds()
+    if len(feeds) != 1 || feeds[0].Loc != "/feed.xml" {
+        t.Fatalf("Expected only /feed.xml to be a feed, got %+v", feeds)
+    }
+
+    data, err := sm.marshalURLSet("sitemap.xml", sm.URLs)
+    if err != nil {
+        t.Fatalf("Error marshaling sitemap: %v", err)
+    }
+    if strings.Contains(string(data), "FeedURL") || strings.Count(string(data), "<url>") != 2 {
+        t.Fatalf("Expected feeds to be emitted as plain URLs, got:\n%s", data)
+    }
+}
+
+func TestAddURLValidatedPriorityPrecision(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_precision", "https://www.example.com")
+
+    if err := sm.AddURLValidated(SitemapURL{Loc: "/a", Priority: "0.55"}); err == nil {
+        t.Fatalf("Expected priority 0.55 to be flagged")
+    }
+    if err := sm.AddURLValidated(SitemapURL{Loc: "/b", Priority: "0.5"}); err != nil {
+        t.Fatalf("Expected priority 0.5 to be accepted, got: %v", err)
+    }
+    if len(sm.URLs) != 1 {
+        t.Fatalf("Expected only the valid URL to be added, got %d", len(sm.URLs))
+    }
+
+    sm.RoundPriority = true
+    if err := sm.AddURLValidated(SitemapURL{Loc: "/c", Priority: "0.55"}); err != nil {
+        t.Fatalf("Expected priority 0.55 to be rounded, got: %v", err)
+    }
+    if sm.URLs[1].Priority != "0.6" {
+        t.Fatalf("Expected priority rounded to 0.6, got %s", sm.URLs[1].Priority)
+    }
+}
+
+func TestAddURLChecked(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_checked", "https://www.example.com")
+
+    for _, tc := range []struct {
+        url      SitemapURL
+        expected string
+    }{
+        {SitemapURL{Loc: " "}, "missing loc"},
+        {SitemapURL{Loc: "mailto:me@example.com"}, "not an absolute http(s) URL"},
+        {SitemapURL{Loc: "/a", ChangeFreq: "sometimes"}, "invalid changefreq 'sometimes' for '/a'"},
+        {SitemapURL{Loc: "/a", Priority: "1.5"}, "invalid priority '1.5' for '/a'"},
+        {SitemapURL{Loc: "/a", Priority: "high"}, "invalid priority"},
+    } {
+        if err := sm.AddURLChecked(tc.url); err == nil || !strings.Contains(err.Error(), tc.expected) {
+            t.Fatalf("Expected %+v to be rejected with %q, got: %v", tc.url, tc.expected, err)
+        }
+    }
+    if len(sm.URLs) != 0 {
+        t.Fatalf("Expected rejected URLs not to be added, got %d", len(sm.URLs))
+    }
+
+    if err := sm.AddURLChecked(SitemapURL{Loc: "/a", ChangeFreq: "weekly", Priority: "0.5"}); err != nil {
+        t.Fatalf("Expected a valid URL to be added: %v", err)
+    }
+    if len(sm.URLs) != 1 {
+        t.Fatalf("Expected 1 URL, got %d", len(sm.URLs))
+    }
+}
+
+func TestIndexOrderLastModDesc(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_index_order", "https://www.example.com")
+    sm.MaxURLs = 2
+    sm.IndexOrder = LastModDesc
+    sm.AddURLs([]SitemapURL{
+        {Loc: "/a", LastMod: "2023-01-01"},
+        {Loc: "/b", LastMod: "2023-02-01"},
+        {Loc: "/c", LastMod: "2023-06-01"},
+        {Loc: "/d", LastMod: "2023-03-01"},
+        {Loc: "/e", LastMod: "2023-04-01"},
+    })
+
+    files, err := sm.WriteToMemory("https://www.example.com/sitemaps/")
+    if err != nil {
+        t.Fatalf("Error writing sitemaps: %v", err)
+    }
+
+    index := string(files["sitemap_index.xml"])
+    second := strings.Index(index, "sitemap_2.xml")
+    third := strings.Index(index, "sitemap_3.xml")
+    first := strings.Index(index, "sitemap_1.xml")
+    if second < 0 || !(second < third && third < first) {
+        t.Fatalf("Expected index ordered sitemap_2, sitemap_3, sitemap_1, got:\n%s", index)
+    }
+    if !strings.Contains(string(files["sitemap_2.xml"]), "/c</loc>") || !strings.Contains(string(files["sitemap_1.xml"]), "/a</loc>") {
+        t.Fatalf("Expected shard contents to keep their filenames")
+    }
+}
+
+func TestTimeZoneToday(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_timezone", "https://www.example.com")
+    sm.clock = func() time.Time { return time.Date(2023, 10, 25, 23, 30, 0, 0, time.UTC) }
+
+    sm.AddURL(SitemapURL{Loc: "/utc"})
+    if sm.URLs[0].LastMod != "2023-10-25" {
+        t.Fatalf("Expected the UTC date by default, got %s", sm.URLs[0].LastMod)
+    }
+
+    tokyo := time.FixedZone("JST", 9*60*60)
+    sm.TimeZone = tokyo
+    sm.AddURL(SitemapURL{Loc: "/tokyo"})
+    sm.AddURL(SitemapURL{Loc: "/tokyo-today", LastMod: "2023-10-26"})
+    if sm.URLs[1].LastMod != "2023-10-26" {
+        t.Fatalf("Expected today's date in the configured zone, got %s", sm.URLs[1].LastMod)
+    }
+    if sm.URLs[2].LastMod != "2023-10-26" {
+        t.Fatalf("Expected a lastmod of today in the configured zone to be kept, got %s", sm.URLs[2].LastMod)
+    }
+}
+
+func TestRequireExtensionSchema(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_require_schema", "https://www.example.com")
+    sm.FS = NewMemFS()
+    sm.AddURL(SitemapURL{
+        Loc:     "/gallery",
+        LastMod: "2023-10-25",
+        Extensions: []Extension{{
+            XMLName: xml.Name{Space: "http://www.google.com/schemas/sitemap-image/1.1", Local: "image"},
+        }},
+    })
+
+    // Without the flag the bundled schema lets the extension through
+    if err := sm.Write(""); err != nil {
+        t.Fatalf("Expected lax validation to pass, got: %v", err)
+    }
+
+    sm.RequireExtensionSchema = true
+    err := sm.Write("")
+    if err == nil || !strings.Contains(err.Error(), "sitemap-image/1.1") {
+        t.Fatalf("Expected an error for the uncovered image namespace, got: %v", err)
+    }
+
+    // A configured image schema covers the namespace
+    sm.SchemaSources = []string{"testdata/sitemap.xsd", "testdata/sitemap-image.xsd"}
+    covered, err := sm.extensionSchemas()
+    if err != nil {
+        t.Fatalf("Error reading schema namespaces: %v", err)
+    }
+    if err := checkExtensionSchema(sm.URLs[0], covered); err != nil {
+        t.Fatalf("Expected the image namespace to be covered, got: %v", err)
+    }
+}
+
+func TestTransforms(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_transforms", "http://www.example.com")
+    sm.FS = NewMemFS()
+    sm.AddURL(SitemapURL{Loc: "/a?utm_source=feed", LastMod: "2023-10-25"})
+    sm.AddURL(SitemapURL{Loc: "/drop", LastMod: "2023-10-25"})
+
+    var seen []string
+    stripTracking := func(u SitemapURL) (SitemapURL, bool) {
+        seen = append(seen, "strip "+u.Loc)
+        u.Loc = strings.SplitN(u.Loc, "?", 2)[0]
+        return u, !strings.HasSuffix(u.Loc, "/drop")
+    }
+    forceHTTPS := func(u SitemapURL) (SitemapURL, bool) {
+        seen = append(seen, "https "+u.Loc)
+        u.Loc = strings.Replace(u.Loc, "http://", "https://", 1)
+        return u, true
+    }
+    sm.Transforms = []func(SitemapURL) (SitemapURL, bool){stripTracking, forceHTTPS}
+
+    if err := sm.Write(""); err != nil {
+        t.Fatalf("Error writing sitemap: %v", err)
+    }
+    if len(sm.prepared) != 1 || sm.prepared[0].Loc != "https://www.example.com/a" {
+        t.Fatalf("Expected only the transformed /a to be kept, got %+v", sm.prepared)
+    }
+    expected := []string{
+        "strip http://www.example.com/a?utm_source=feed",
+        "https http://www.example.com/a",
+        "strip http://www.example.com/drop",
+    }
+    if strings.Join(seen, "\n") != strings.Join(expected, "\n") {
+        t.Fatalf("Expected transforms to run in order, got %v", seen)
+    }
+}
+
+func TestTransformsLeaveURLsUntouched(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_transforms_untouched", "https://www.example.com")
+    sm.FS = NewMemFS()
+    sm.Transforms = []func(SitemapURL) (SitemapURL, bool){
+        func(u SitemapURL) (SitemapURL, bool) {
+            u.Loc = strings.Replace(u.Loc, ".com/", ".com/en/", 1)
+            return u, !strings.HasSuffix(u.Loc, "/drop")
+        },
+    }
+    sm.AddURL(SitemapURL{Loc: "/drop", LastMod: "2023-10-25"})
+    sm.AddURL(SitemapURL{Loc: "/page", LastMod: "2023-10-25"})
+
+    // Every write transforms the URLs as added, not the previous output
+    for i := 0; i < 3; i++ {
+        files, err := sm.WriteToMemory("")
+        if err != nil {
+            t.Fatalf("Error writing sitemap: %v", err)
+        }
+        if !strings.Contains(string(files["sitemap.xml"]), "<loc>https://www.example.com/en/page</loc>") {
+            t.Fatalf("Expected write %d to transform /page once, got:\n%s", i+1, files["sitemap.xml"])
+        }
+    }
+    if len(sm.URLs) != 2 || sm.URLs[0].Loc != "/drop" || sm.URLs[1].Loc != "/page" {
+        t.Fatalf("Expected URLs to be left as added, got %+v", sm.URLs)
+    }
+
+    // A failed write leaves them untouched too
+    sm.AddURL(SitemapURL{Loc: "/a", LastMod: "2023-10-25"})
+    sm.AddURL(SitemapURL{Loc: "/b", LastMod: "2023-10-25", Alternates: []Alternate{{Href: "/fr/b"}}})
+    if _, err := sm.WriteToMemory(""); err == nil {
+        t.Fatalf("Expected an alternate without hreflang to fail the write")
+    }
+    locs := make([]string, len(sm.URLs))
+    for i, u := range sm.URLs {
+        locs[i] = u.Loc
+    }
+    if strings.Join(locs, ",") != "/drop,/page,/a,/b" {
+        t.Fatalf("Expected URLs to survive the failed write, got %v", locs)
+    }
+}
+
+func TestPriorityFloor(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_priority_floor", "https://www.example.com")
+    sm.FS = NewMemFS()
+    sm.PriorityFloor = 0.5
+    sm.AddURL(SitemapURL{Loc: "/high", LastMod: "2023-10-25", Priority: "0.8"})
+    sm.AddURL(SitemapURL{Loc: "/floor", LastMod: "2023-10-25", Priority: "0.5"})
+    sm.AddURL(SitemapURL{Loc: "/low", LastMod: "2023-10-25", Priority: "0.2"})
+
+    files, err := sm.WriteToMemory("")
+    if err != nil {
+        t.Fatalf("Error writing sitemap: %v", err)
+    }
+    data := string(files["sitemap.xml"])
+    if !strings.Contains(data, "<priority>0.8</priority>") || !strings.Contains(data, "<priority>0.5</priority>") {
+        t.Fatalf("Expected priorities at or above the floor to be emitted, got:\n%s", data)
+    }
+    if strings.Contains(data, "<priority>0.2</priority>") || strings.Count(data, "<priority>") != 2 {
+        t.Fatalf("Expected the priority below the floor to be omitted, got:\n%s", data)
+    }
+}
+
+func TestShardNamePrediction(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_shard_names", "https://www.example.com")
+    sm.FS = NewMemFS()
+    sm.MaxURLs = 1
+    sm.DirLayout = ByYear
+    sm.AddURL(SitemapURL{Loc: "/a", LastMod: "2022-05-01"})
+    sm.AddURL(SitemapURL{Loc: "/b", LastMod: "2023-05-01"})
+    sm.AddURL(SitemapURL{Loc: "/c", LastMod: "2023-06-01"})
+
+    if err := sm.Write("https://www.example.com/sitemaps/"); err != nil {
+        t.Fatalf("Error writing sitemaps: %v", err)
+    }
+    written := map[string]bool{}
+    for _, file := range sm.Files() {
+        written[file.Name] = true
+    }
+    for _, name := range []string{sm.ShardName("2022", 1, nil), sm.ShardName("2023", 1, nil), sm.ShardName("2023", 2, nil)} {
+        if !written[name] {
+            t.Fatalf("Expected predicted %s to be written, got %v", name, written)
+        }
+    }
+    if sm.ShardName("", 3, nil) != "sitemap_3.xml" {
+        t.Fatalf("Expected flat shard names to have no directory, got %s", sm.ShardName("", 3, nil))
+    }
+
+    // Gzip and ShardFilename apply to predicted names as they do on Write
+    sm.DirLayout = Flat
+    sm.Gzip = true
+    sm.ShardFilename = func(n int, urls []SitemapURL) string {
+        return fmt.Sprintf("pages-%d-%d.xml", n, len(urls))
+    }
+    if err := sm.Write("https://www.example.com/sitemaps/"); err != nil {
+        t.Fatalf("Error writing sitemaps: %v", err)
+    }
+    written = map[string]bool{}
+    for _, file := range sm.Files() {
+        written[file.Name] = true
+    }
+    for n, u := range sm.URLs {
+        name := sm.ShardName("", n+1, []SitemapURL{u})
+        if name != fmt.Sprintf("pages-%d-1.xml.gz", n+1) || !written[name] {
+            t.Fatalf("Expected predicted %s to be written as shard %d, got %v", name, n+1, written)
+        }
+    }
+}
+
+func TestMinLastMod(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_min_lastmod", "https://www.example.com")
+    sm.AddURL(SitemapURL{Loc: "/unbounded", LastMod: "1970-01-01"})
+
+    sm.MinLastMod = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+    sm.AddURL(SitemapURL{Loc: "/epoch", LastMod: "1970-01-01"})
+    sm.AddURL(SitemapURL{Loc: "/recent", LastMod: "2021-03-04"})
+
+    expected := []string{"1970-01-01", "2020-01-01", "2021-03-04"}
+    for i, lastMod := range expected {
+        if sm.URLs[i].LastMod != lastMod {
+            t.Fatalf("Expected lastmod %s for %s, got %s", lastMod, sm.URLs[i].Loc, sm.URLs[i].LastMod)
+        }
+    }
+}
+
+func TestLocWhitespace(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_loc_whitespace", "https://www.example.com")
+    sm.FS = NewMemFS()
+    sm.AddURL(SitemapURL{Loc: "  \n/harvested \r\n", LastMod: "2023-10-25"})
+    if err := sm.Write(""); err != nil {
+        t.Fatalf("Error writing sitemap: %v", err)
+    }
+    if sm.prepared[0].Loc != "https://www.example.com/harvested" {
+        t.Fatalf("Expected surrounding whitespace to be trimmed, got %q", sm.prepared[0].Loc)
+    }
+
+    sm.URLs = nil
+    sm.AddURL(SitemapURL{Loc: "/tab\tinside", LastMod: "2023-10-25"})
+    if err := sm.Write(""); err == nil || !strings.Contains(err.Error(), "control characters") {
+        t.Fatalf("Expected a loc with an interior tab to be rejected, got: %v", err)
+    }
+}
+
+func TestInvalidUTF8(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_utf8", "https://www.example.com")
+    sm.FS = NewMemFS()
+    sm.AddURL(SitemapURL{Loc: "/caf\xe9", LastMod: "2023-10-25"})
+    if err := sm.Write(""); err == nil || !strings.Contains(err.Error(), "invalid UTF-8") {
+        t.Fatalf("Expected a loc with invalid UTF-8 to be rejected, got: %v", err)
+    }
+
+    sm.URLs = nil
+    sm.AddURL(SitemapURL{
+        Loc:     "/caf\u00e9",
+        LastMod: "2023-10-25",
+        Extensions: []Extension{{
+            XMLName:  xml.Name{Space: "https://vendor.example.com/ns", Local: "meta"},
+            Children: []Extension{{XMLName: xml.Name{Local: "title"}, Value: "bad \xff byte"}},
+        }},
+    })
+    if err := sm.Write(""); err == nil || !strings.Contains(err.Error(), "invalid UTF-8") {
+        t.Fatalf("Expected an extension with invalid UTF-8 to be rejected, got: %v", err)
+    }
+
+    sm.URLs[0].Extensions = nil
+    if err := sm.Write(""); err != nil {
+        t.Fatalf("Expected a valid UTF-8 loc to be written: %v", err)
+    }
+}
+
+func TestAdaptiveSplitMode(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_adaptive", "https://www.example.com")
+    sm.FS = NewMemFS()
+    sm.SplitMode = Adaptive
+    sm.MaxFileSize = 2048
+    sm.MaxURLs = 5 // Ignored in adaptive mode
+    for i := 0; i < 60; i++ {
+        sm.AddURL(SitemapURL{
+            Loc:     "/page/" + strings.Repeat("x", (i*37)%120) + "/" + strconv.Itoa(i),
+            LastMod: "2023-10-25",
+        })
+    }
+
+    files, err := sm.WriteToMemory("https://www.example.com/sitemaps/")
+    if err != nil {
+        t.Fatalf("Error writing sitemaps: %v", err)
+    }
+
+    shards := 0
+    total := 0
+    for _, file := range sm.Files() {
+        if !strings.HasPrefix(file.Name, "sitemap_") || file.Name == "sitemap_index.xml" {
+            continue
+        }
+        shards++
+        total += file.URLCount
+        if file.Size > sm.MaxFileSize {
+            t.Fatalf("%s is %d bytes, over the %d byte budget", file.Name, file.Size, sm.MaxFileSize)
+        }
+        // Every shard but the last is filled to within one of the longest
+        // URL entries of the budget
+        if _, full := files[sm.ShardName("", shards+1, nil)]; full {
+            if file.Size < sm.MaxFileSize-300 {
+                t.Fatalf("Expected %s to be filled near the budget, got %d bytes", file.Name, file.Size)
+            }
+            if file.URLCount <= sm.MaxURLs {
+                t.Fatalf("Expected %s to hold more than MaxURLs URLs, got %d", file.Name, file.URLCount)
+            }
+        }
+    }
+    if shards < 2 || total != 60 {
+        t.Fatalf("Expected 60 URLs over several shards, got %d over %d", total, shards)
+    }
+}
+
+func TestMaxFileSizeSplitsFixedCount(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_max_file_size", "https://www.example.com")
+    sm.FS = NewMemFS()
+    sm.MaxURLs = 30
+    sm.MaxFileSize = 2048
+    // The URLs fit a single file by count, but not by size
+    for i := 0; i < 20; i++ {
+        sm.AddURL(SitemapURL{Loc: "/long/" + strings.Repeat("y", 100) + "/" + strconv.Itoa(i), LastMod: "2023-10-25"})
+    }
+
+    files, err := sm.WriteToMemory("https://www.example.com/sitemaps/")
+    if err != nil {
+        t.Fatalf("Error writing sitemaps: %v", err)
+    }
+    if _, ok := files["sitemap_index.xml"]; !ok {
+        t.Fatalf("Expected an index once the URLs exceed MaxFileSize")
+    }
+
+    total := 0
+    for _, file := range sm.Files() {
+        if file.Size > sm.MaxFileSize {
+            t.Fatalf("%s is %d bytes, over MaxFileSize %d", file.Name, file.Size, sm.MaxFileSize)
+        }
+        if file.URLCount > 0 {
+            total += file.URLCount
+            if !strings.Contains(string(files["sitemap_index.xml"]), "/sitemaps/"+file.Name+"</loc>") {
+                t.Fatalf("Expected the index to list %s", file.Name)
+            }
+        }
+    }
+    if len(sm.Files()) < 4 || total != 20 {
+        t.Fatalf("Expected 20 URLs split over several shards, got %d in %v", total, sm.Files())
+    }
+}
+
+func TestBuildTime(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_build_time", "https://www.example.com")
+    sm.FS = NewMemFS()
+    sm.MaxURLs = 2
+    sm.BuildTime = time.Date(2023, 3, 14, 15, 9, 26, 0, time.UTC)
+    sm.clock = func() time.Time { return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC) }
+    for i := 0; i < 3; i++ {
+        sm.AddURL(SitemapURL{Loc: "/page/" + strconv.Itoa(i)})
+    }
+    sm.AddURL(SitemapURL{Loc: "/dated", LastMod: "2022-01-01"})
+
+    for _, u := range sm.URLs[:3] {
+        if u.LastMod != "2023-03-14" {
+            t.Fatalf("Expected defaulted lastmod to be the build date, got %s for %s", u.LastMod, u.Loc)
+        }
+    }
+    if sm.URLs[3].LastMod != "2022-01-01" {
+        t.Fatalf("Expected an explicit lastmod to be kept, got %s", sm.URLs[3].LastMod)
+    }
+
+    files, err := sm.WriteToMemory("https://www.example.com/sitemaps/")
+    if err != nil {
+        t.Fatalf("Error writing sitemaps: %v", err)
+    }
+    index := string(files["sitemap_index.xml"])
+    if strings.Count(index, "<lastmod>2023-03-14</lastmod>") != 2 {
+        t.Fatalf("Expected index lastmods to be the build date, got:\n%s", index)
+    }
+}
+
+func TestMaxTotalBytes(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_max_total", "https://www.example.com")
+    sm.FS = NewMemFS()
+    sm.MaxURLs = 2
+    sm.IncludeStylesheet = false
+    for i := 0; i < 10; i++ {
+        sm.AddURL(SitemapURL{Loc: "/page/" + strconv.Itoa(i), LastMod: "2023-10-25"})
+    }
+    shard, _ := sm.marshalURLSet("sitemap_1.xml", sm.URLs[:2])
+    sm.MaxTotalBytes = 2*len(shard) + len(shard)/2
+
+    files, err := sm.WriteToMemory("https://www.example.com/sitemaps/")
+    if err != nil {
+        t.Fatalf("Error writing sitemaps: %v", err)
+    }
+    if _, ok := files["sitemap_2.xml"]; !ok {
+        t.Fatalf("Expected the shards within budget to be written")
+    }
+    if _, ok := files["sitemap_3.xml"]; ok {
+        t.Fatalf("Expected writing to stop before the budget is exceeded")
+    }
+    index := string(files["sitemap_index.xml"])
+    if strings.Count(index, "<sitemap>") != 2 {
+        t.Fatalf("Expected the index to reference only the written shards, got:\n%s", index)
+    }
+    if sm.OmittedURLs() != 6 {
+        t.Fatalf("Expected 6 omitted URLs, got %d", sm.OmittedURLs())
+    }
+
+    sm.MaxTotalBytes = 10
+    if err := sm.Write("https://www.example.com/sitemaps/"); err == nil {
+        t.Fatalf("Expected an error when not even one shard fits")
+    }
+
+    // With Gzip the budget counts the compressed bytes written
+    sm.Gzip = true
+    compressed, _ := gzipBytes(shard)
+    sm.MaxTotalBytes = 3*len(compressed) + len(compressed)/2
+    files, err = sm.WriteToMemory("https://www.example.com/sitemaps/")
+    if err != nil {
+        t.Fatalf("Error writing gzipped sitemaps: %v", err)
+    }
+    if _, ok := files["sitemap_3.xml.gz"]; !ok {
+        t.Fatalf("Expected the budget to be measured against compressed shards")
+    }
+    if _, ok := files["sitemap_4.xml.gz"]; ok {
+        t.Fatalf("Expected writing to stop before the compressed budget is exceeded")
+    }
+}
+
+func TestIndexOrderKeepsShardContents(t *testing.T) {
+    urls := []SitemapURL{
+        {Loc: "/old-1", LastMod: "2021-01-01"},
+        {Loc: "/old-2", LastMod: "2021-02-01"},
+        {Loc: "/new-1", LastMod: "2023-01-01"},
+        {Loc: "/new-2", LastMod: "2023-02-01"},
+    }
+    write := func(order IndexOrder) map[string][]byte {
+        sm := NewSitemapOptions("./test_sitemaps_index_order_stable", "https://www.example.com")
+        sm.MaxURLs = 2
+        sm.IndexOrder = order
+        sm.AddURLs(urls)
+        files, err := sm.WriteToMemory("https://www.example.com/sitemaps/")
+        if err != nil {
+            t.Fatalf("Error writing sitemaps: %v", err)
+        }
+        return files
+    }
+
+    numeric := write(Numeric)
+    fresh := write(LastModDesc)
+    for _, name := range []string{"sitemap_1.xml", "sitemap_2.xml"} {
+        if string(numeric[name]) != string(fresh[name]) {
+            t.Fatalf("Expected %s to be unchanged by the index order", name)
+        }
+    }
+    if string(numeric["sitemap_index.xml"]) == string(fresh["sitemap_index.xml"]) {
+        t.Fatalf("Expected the index order to differ from numeric")
+    }
+    index := string(fresh["sitemap_index.xml"])
+    if strings.Index(index, "sitemap_2.xml") > strings.Index(index, "sitemap_1.xml") {
+        t.Fatalf("Expected the freshest shard first, got:\n%s", index)
+    }
+}
+
+func TestShardFilename(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_shard_filename", "https://www.example.com")
+    sm.FS = NewMemFS()
+    sm.MaxURLs = 2
+    sm.ShardFilename = func(n int, urls []SitemapURL) string {
+        return "cdn/" + strings.TrimPrefix(urls[0].Loc, "https://www.example.com/") + "-" + strconv.Itoa(n) + ".xml"
+    }
+    sm.AddURLs([]SitemapURL{{Loc: "/a"}, {Loc: "/b"}, {Loc: "/c"}})
+
+    files, err := sm.WriteToMemory("https://www.example.com/sitemaps/")
+    if err != nil {
+        t.Fatalf("Error writing sitemaps: %v", err)
+    }
+    index := string(files["sitemap_index.xml"])
+    for _, name := range []string{"cdn/a-1.xml", "cdn/c-2.xml"} {
+        if _, ok := files[name]; !ok {
+            t.Fatalf("Expected shard %s to be written, got %v", name, sm.Files())
+        }
+        if !strings.Contains(index, "https://www.example.com/sitemaps/"+name) {
+            t.Fatalf("Expected the index to reference %s, got:\n%s", name, index)
+        }
+    }
+    if _, ok := files["sitemap_1.xml"]; ok {
+        t.Fatalf("Expected no default shard names")
+    }
+
+    for _, name := range []string{"../escape.xml", "/abs.xml", "a:b.xml", "sitemap_index.xml"} {
+        sm.ShardFilename = func(int, []SitemapURL) string { return name }
+        if err := sm.Write("https://www.example.com/sitemaps/"); err == nil {
+            t.Fatalf("Expected shard filename %q to be rejected", name)
+        }
+    }
+    sm.ShardFilename = func(int, []SitemapURL) string { return "same.xml" }
+    if err := sm.Write("https://www.example.com/sitemaps/"); err == nil || !strings.Contains(err.Error(), "several shards") {
+        t.Fatalf("Expected duplicate shard filenames to be rejected, got: %v", err)
+    }
+}
+
+func TestCanonicalHost(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_canonical_host", "http://staging.example.com")
+    sm.FS = NewMemFS()
+    sm.CanonicalHost = "https://www.example.com"
+    sm.AddURL(SitemapURL{Loc: "http://staging.example.com/a?page=2", LastMod: "2023-10-25"})
+    sm.AddURL(SitemapURL{Loc: "/b#top", LastMod: "2023-10-25"})
+    sm.AddURL(SitemapURL{
+        Loc:        "/c",
+        LastMod:    "2023-10-25",
+        AMPURL:     "/c/amp",
+        Images:     []SitemapImage{{Loc: "/c.jpg"}, {Loc: "https://cdn.example.net/c.jpg"}},
+        Alternates: []Alternate{{Hreflang: "fr", Href: "http://staging.example.com/fr/c"}},
+    })
+
+    if err := sm.Write(""); err != nil {
+        t.Fatalf("Error writing sitemap: %v", err)
+    }
+    expected := []string{"https://www.example.com/a?page=2", "https://www.example.com/b#top", "https://www.example.com/c"}
+    for i, loc := range expected {
+        if sm.prepared[i].Loc != loc {
+            t.Fatalf("Expected loc %s, got %s", loc, sm.prepared[i].Loc)
+        }
+    }
+
+    // Links on the loc's host follow it, those on other hosts are kept
+    u := sm.prepared[2]
+    if u.AMPURL != "https://www.example.com/c/amp" {
+        t.Fatalf("Expected the AMP URL on the canonical host, got %s", u.AMPURL)
+    }
+    if u.Images[0].Loc != "https://www.example.com/c.jpg" || u.Images[1].Loc != "https://cdn.example.net/c.jpg" {
+        t.Fatalf("Expected only the image on the loc's host to be rewritten, got %+v", u.Images)
+    }
+    if u.Alternates[0].Href != "https://www.example.com/fr/c" {
+        t.Fatalf("Expected the alternate on the canonical host, got %s", u.Alternates[0].Href)
+    }
+
+    sm.CanonicalHost = "www.example.com"
+    if err := sm.Write(""); err == nil {
+        t.Fatalf("Expected a CanonicalHost without a scheme to be rejected")
+    }
+}
+
+// corruptFS mangles the shards named in corrupt as they are written.
+type corruptFS struct {
+    *MemFS
+    corrupt map[string]bool
+}
+
+func (fs *corruptFS) WriteFile(name string, data []byte, perm os.FileMode) error {
+    if fs.corrupt[path.Base(name)] {
+        data = bytes.ReplaceAll(data, []byte("loc>"), []byte("location>"))
+    }
+    return fs.MemFS.WriteFile(name, data, perm)
+}
+
+func TestDropInvalidShards(t *testing.T) {
+    fs := &corruptFS{MemFS: NewMemFS(), corrupt: map[string]bool{"sitemap_2.xml": true}}
+    sm := NewSitemapOptions("./test_sitemaps_drop_invalid", "https://www.example.com")
+    sm.FS = fs
+    sm.MaxURLs = 1
+    for _, loc := range []string{"/a", "/b", "/c"} {
+        sm.AddURL(SitemapURL{Loc: loc, LastMod: "2023-10-25"})
+    }
+
+    if err := sm.Write("https://www.example.com/"); err == nil {
+        t.Fatalf("Expected the corrupt shard to fail Write by default")
+    }
+
+    sm.DropInvalidShards = true
+    if err := sm.Write("https://www.example.com/"); err != nil {
+        t.Fatalf("Error writing sitemaps: %v", err)
+    }
+    data, err := fs.ReadFile("test_sitemaps_drop_invalid/sitemap_index.xml")
+    if err != nil {
+        t.Fatalf("Error reading index: %v", err)
+    }
+    if strings.Contains(string(data), "sitemap_2.xml") {
+        t.Fatalf("Expected sitemap_2.xml to be dropped from the index:\n%s", data)
+    }
+    if !strings.Contains(string(data), "sitemap_1.xml") || !strings.Contains(string(data), "sitemap_3.xml") {
+        t.Fatalf("Expected the valid shards to stay in the index:\n%s", data)
+    }
+    if warnings := sm.Warnings(); len(warnings) != 1 || !strings.Contains(warnings[0], "sitemap_2.xml") {
+        t.Fatalf("Expected the dropped shard to be reported, got %v", warnings)
+    }
+    var names []string
+    for _, file := range sm.Files() {
+        names = append(names, file.Name)
+    }
+    if strings.Join(names, ",") != "sitemap.xsl,sitemap_1.xml,sitemap_3.xml,sitemap_index.xml" {
+        t.Fatalf("Unexpected files after dropping a shard: %v", names)
+    }
+
+    fs.corrupt = map[string]bool{"sitemap_1.xml": true, "sitemap_2.xml": true, "sitemap_3.xml": true}
+    if err := sm.Write("https://www.example.com/"); err == nil {
+        t.Fatalf("Expected Write to fail when every shard is invalid")
+    }
+}
+
+func TestForceBasePath(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_base_path", "https://host/app")
+    cases := map[string]string{
+        "https://host/products/1":     "https://host/products/1",
+        "https://host/app/products/2": "https://host/app/products/2",
+        "https://other/products/3":    "https://other/products/3",
+    }
+    for loc, expected := range cases {
+        if resolved, err := sm.resolveURL(loc); err != nil || resolved != expected {
+            t.Fatalf("Expected %s to resolve to %s without ForceBasePath, got %s (%v)", loc, expected, resolved, err)
+        }
+    }
+
+    sm.ForceBasePath = true
+    cases["https://host/products/1"] = "https://host/app/products/1"
+    for loc, expected := range cases {
+        if resolved, err := sm.resolveURL(loc); err != nil || resolved != expected {
+            t.Fatalf("Expected %s to resolve to %s with ForceBasePath, got %s (%v)", loc, expected, resolved, err)
+        }
+    }
+}
+
+func TestGeneratorTag(t *testing.T) {
+    fs := NewMemFS()
+    sm := NewSitemapOptions("./test_sitemaps_generator", "https://www.example.com")
+    sm.FS = fs
+    sm.MaxURLs = 1
+    sm.GeneratorTag = "nyxsitemap 1.2.3"
+    sm.AddURL(SitemapURL{Loc: "/a", LastMod: "2023-10-25"})
+    sm.AddURL(SitemapURL{Loc: "/b", LastMod: "2023-10-25"})
+
+    // Write validates every file against the XSDs
+    if err := sm.Write("https://www.example.com/"); err != nil {
+        t.Fatalf("Error writing sitemaps: %v", err)
+    }
+    prolog := "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<?generator nyxsitemap 1.2.3?>\n"
+    for _, name := range []string{"sitemap_index.xml", "sitemap_1.xml", "sitemap_2.xml"} {
+        data, err := fs.ReadFile("test_sitemaps_generator/" + name)
+        if err != nil {
+            t.Fatalf("Error reading %s: %v", name, err)
+        }
+        if !strings.HasPrefix(string(data), prolog) {
+            t.Fatalf("Expected the generator tag after the declaration of %s:\n%s", name, data)
+        }
+    }
+
+    sm.GeneratorTag = "bad ?> tag"
+    if err := sm.Write("https://www.example.com/"); err == nil {
+        t.Fatalf("Expected a generator tag closing the instruction to be rejected")
+    }
+}
+
+func TestIndexReferencesMissingShard(t *testing.T) {
+    dir := "./test_sitemaps_missing_shard"
+    os.RemoveAll(dir)
+    defer os.RemoveAll(dir)
+
+    sm := NewSitemapOptions(dir, "https://www.example.com")
+    sm.MaxURLs = 1
+    sm.SkipValidationFor = func(string) bool { return true }
+    for _, loc := range []string{"/a", "/b", "/c"} {
+        sm.AddURL(SitemapURL{Loc: loc, LastMod: "2023-10-25"})
+    }
+    if err := sm.Write("https://www.example.com/"); err != nil {
+        t.Fatalf("Error writing sitemaps: %v", err)
+    }
+
+    if err := os.Remove(path.Join(dir, "sitemap_2.xml")); err != nil {
+        t.Fatalf("Error removing shard: %v", err)
+    }
+    // The check applies even to files whose validation is skipped
+    err := sm.validateSitemapIndexAndFiles("https://www.example.com/")
+    if err == nil || !strings.Contains(err.Error(), "missing") || !strings.Contains(err.Error(), "sitemap_2.xml") {
+        t.Fatalf("Expected the missing shard to be reported, got: %v", err)
+    }
+}
+
+func TestVerifySplit(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_verify_split", "https://www.example.com")
+    sm.FS = NewMemFS()
+    sm.MaxURLs = 2
+    sm.SplitMode = Adaptive
+    sm.MaxFileSize = 400
+    sm.VerifySplit = true
+    for _, loc := range []string{"/a", "/b", "/c", "/d", "/a"} {
+        sm.AddURL(SitemapURL{Loc: loc, LastMod: "2023-10-25"})
+    }
+    if err := sm.Write("https://www.example.com/"); err != nil {
+        t.Fatalf("Expected the built-in split to pass verification: %v", err)
+    }
+
+    // A buggy splitter dropping one URL and repeating another
+    urls := sm.prepared
+    buggy := []sitemapShard{
+        {name: "sitemap_1.xml", urls: urls[0:2]},
+        {name: "sitemap_2.xml", urls: append([]SitemapURL{urls[1]}, urls[2:3]...)},
+        {name: "sitemap_3.xml", urls: urls[4:5]},
+    }
+    err := verifySplit(urls, buggy)
+    if err == nil {
+        t.Fatalf("Expected the buggy split to be caught")
+    }
+    if !strings.Contains(err.Error(), "missing 'https://www.example.com/d'") ||
+        !strings.Contains(err.Error(), "'https://www.example.com/b' in sitemap_2.xml") {
+        t.Fatalf("Expected the dropped and repeated URLs to be named, got: %v", err)
+    }
+}
+
+func TestLastModPrecision(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_lastmod_precision", "https://www.example.com")
+    sm.AddURL(SitemapURL{Loc: "/category", LastMod: "2023-10-25"})
+    sm.AddURL(SitemapURL{Loc: "/article", LastMod: "2023-10-25T14:30:00+02:00"})
+    sm.AddURL(SitemapURL{Loc: "/precise", LastMod: "2023-10-25T14:30:00.5Z"})
+
+    // Write validates both forms against the bundled schema
+    files, err := sm.WriteToMemory("")
+    if err != nil {
+        t.Fatalf("Error writing sitemap: %v", err)
+    }
+    data := string(files["sitemap.xml"])
+    for _, lastMod := range []string{"2023-10-25", "2023-10-25T14:30:00+02:00", "2023-10-25T14:30:00.5Z"} {
+        if !strings.Contains(data, "<lastmod>"+lastMod+"</lastmod>") {
+            t.Fatalf("Expected lastmod %s to keep its precision, got:\n%s", lastMod, data)
+        }
+    }
+
+    sm.URLs = nil
+    sm.AddURL(SitemapURL{Loc: "/future", LastMod: "2999-01-01T00:00:00Z"})
+    if sm.URLs[0].LastMod != sm.today() {
+        t.Fatalf("Expected a future datetime to be capped to today, got %s", sm.URLs[0].LastMod)
+    }
+}
+
+func TestLastModTime(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_lastmod_time", "https://www.example.com")
+    zone := time.FixedZone("UTC+2", 2*60*60)
+    sm.AddURL(SitemapURL{Loc: "/article", LastModTime: time.Date(2024, 1, 2, 15, 4, 5, 0, zone)})
+    sm.AddURL(SitemapURL{Loc: "/page", LastModTime: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)})
+    sm.AddURL(SitemapURL{Loc: "/explicit", LastMod: "2023-10-25", LastModTime: time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)})
+    sm.AddURL(SitemapURL{Loc: "/future", LastModTime: time.Date(2999, 1, 1, 12, 0, 0, 0, time.UTC)})
+
+    expected := []string{"2024-01-02T15:04:05+02:00", "2024-01-02", "2023-10-25", sm.today()}
+    for i, u := range sm.URLs {
+        if u.LastMod != expected[i] {
+            t.Fatalf("Expected lastmod %s for %s, got %s", expected[i], u.Loc, u.LastMod)
+        }
+    }
+
+    // Write validates the datetime against the bundled schema
+    if _, err := sm.WriteToMemory(""); err != nil {
+        t.Fatalf("Error writing sitemap: %v", err)
+    }
+}
+
+// tamperFS swaps bytes in files as they are written, keeping them valid.
+type tamperFS struct {
+    *MemFS
+    old, new string
+}
+
+func (fs *tamperFS) WriteFile(name string, data []byte, perm os.FileMode) error {
+    return fs.MemFS.WriteFile(name, bytes.ReplaceAll(data, []byte(fs.old), []byte(fs.new)), perm)
+}
+
+func TestRoundTripVerify(t *testing.T) {
+    for _, maxURLs := range []int{10, 1} {
+        fs := &tamperFS{MemFS: NewMemFS()}
+        sm := NewSitemapOptions("./test_sitemaps_round_trip", "https://www.example.com")
+        sm.FS = fs
+        sm.MaxURLs = maxURLs
+        sm.RoundTripVerify = true
+        sm.AddURL(SitemapURL{Loc: "/a", LastMod: "2023-10-25", Priority: "0.8", Images: []SitemapImage{{Loc: "/a.png"}}})
+        sm.AddURL(SitemapURL{Loc: "/b?x=1&y=2", LastMod: "2023-10-25T10:00:00Z", ChangeFreq: "daily"})
+
+        if err := sm.Write("https://www.example.com/"); err != nil {
+            t.Fatalf("Expected normal output to pass round-trip verification: %v", err)
+        }
+
+        // Still schema-valid, but not what was written
+        fs.old, fs.new = "<priority>0.8</priority>", "<priority>0.3</priority>"
+        err := sm.Write("https://www.example.com/")
+        if err == nil || !strings.Contains(err.Error(), "expected https://www.example.com/a") {
+            t.Fatalf("Expected the altered priority to be caught with MaxURLs %d, got: %v", maxURLs, err)
+        }
+    }
+}
+
+func TestMaxURLsInMemory(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_max_in_memory", "https://www.example.com")
+    sm.MaxURLsInMemory = 3
+
+    if err := sm.AddURLs([]SitemapURL{{Loc: "/a"}, {Loc: "/b"}}); err != nil {
+        t.Fatalf("Expected a batch under the limit to be added: %v", err)
+    }
+    if err := sm.AddURLs([]SitemapURL{{Loc: "/c"}, {Loc: "/d"}}); err == nil {
+        t.Fatalf("Expected a batch past the limit to be rejected")
+    }
+    if len(sm.URLs) != 2 {
+        t.Fatalf("Expected the rejected batch to add nothing, got %d URLs", len(sm.URLs))
+    }
+    if err := sm.AddURL(SitemapURL{Loc: "/c"}); err != nil {
+        t.Fatalf("Expected the URL reaching the limit to be added: %v", err)
+    }
+    if err := sm.AddURL(SitemapURL{Loc: "/d"}); err == nil || !strings.Contains(err.Error(), "MaxURLsInMemory") {
+        t.Fatalf("Expected the URL past the limit to be rejected, got: %v", err)
+    }
+    if err := sm.AddURLValidated(SitemapURL{Loc: "/d", Priority: "0.5"}); err == nil {
+        t.Fatalf("Expected AddURLValidated to honor the limit")
+    }
+    if added := sm.AddRoutes([]string{"/d", "/e"}); added != 0 || len(sm.URLs) != 3 {
+        t.Fatalf("Expected AddRoutes to stop at the limit, added %d", added)
+    }
+}
+
+func TestDeduplicateURLs(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_dedupe", "https://www.example.com")
+    sm.AddURLs([]SitemapURL{
+        {Loc: "/about", LastMod: "2023-01-01"},
+        {Loc: "/contact", LastMod: "2023-01-01"},
+        {Loc: "https://www.example.com/about", LastMod: "2023-06-01"},
+    })
+
+    files, err := sm.WriteToMemory("")
+    if err != nil {
+        t.Fatalf("Error writing sitemap: %v", err)
+    }
+    if strings.Count(string(files["sitemap.xml"]), "<url>") != 3 {
+        t.Fatalf("Expected duplicates to be kept by default, got:\n%s", files["sitemap.xml"])
+    }
+
+    sm.URLs = nil
+    sm.DeduplicateURLs = true
+    sm.AddURLs([]SitemapURL{
+        {Loc: "/about", LastMod: "2023-01-01"},
+        {Loc: "/contact", LastMod: "2023-01-01"},
+        {Loc: "https://www.example.com/about", LastMod: "2023-06-01"},
+    })
+    if _, err := sm.WriteToMemory(""); err != nil {
+        t.Fatalf("Error writing sitemap: %v", err)
+    }
+    if len(sm.prepared) != 2 {
+        t.Fatalf("Expected 2 URLs after deduplication, got %d", len(sm.prepared))
+    }
+    if sm.prepared[0].Loc != "https://www.example.com/about" || sm.prepared[0].LastMod != "2023-06-01" {
+        t.Fatalf("Expected the last /about to win at the first position, got %+v", sm.prepared[0])
+    }
+
+    // The duplicates are only dropped from the written copy
+    if len(sm.URLs) != 3 {
+        t.Fatalf("Expected URLs to keep every entry, got %d", len(sm.URLs))
+    }
+    sm.DeduplicateURLs = false
+    files, err = sm.WriteToMemory("")
+    if err != nil {
+        t.Fatalf("Error writing sitemap: %v", err)
+    }
+    if strings.Count(string(files["sitemap.xml"]), "<url>") != 3 {
+        t.Fatalf("Expected the duplicates back without DeduplicateURLs, got:\n%s", files["sitemap.xml"])
+    }
+}
+
+func TestEmitDefaultPriority(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_default_priority", "https://www.example.com")
+    sm.AddURL(SitemapURL{Loc: "/a", LastMod: "2023-10-25"})
+    sm.AddURL(SitemapURL{Loc: "/b", LastMod: "2023-10-25", Priority: "0.9"})
+
+    files, err := sm.WriteToMemory("")
+    if err != nil {
+        t.Fatalf("Error writing sitemap: %v", err)
+    }
+    if strings.Count(string(files["sitemap.xml"]), "<priority>") != 1 {
+        t.Fatalf("Expected only the explicit priority by default, got:\n%s", files["sitemap.xml"])
+    }
+
+    sm.EmitDefaultPriority = true
+    files, err = sm.WriteToMemory("")
+    if err != nil {
+        t.Fatalf("Error writing sitemap: %v", err)
+    }
+    data := string(files["sitemap.xml"])
+    if !strings.Contains(data, "<loc>https://www.example.com/a</loc>\n    <lastmod>2023-10-25</lastmod>\n    <priority>0.5</priority>") {
+        t.Fatalf("Expected the default priority to be emitted, got:\n%s", data)
+    }
+    if !strings.Contains(data, "<priority>0.9</priority>") {
+        t.Fatalf("Expected the explicit priority to be kept, got:\n%s", data)
+    }
+}
+
+func TestResolveURLHostCase(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_host_case", "https://Www.Example.com")
+    for _, loc := range []string{"HTTPS://WWW.EXAMPLE.COM/Page", "https://www.example.com/Page", "/Page"} {
+        resolved, err := sm.resolveURL(loc)
+        if err != nil {
+            t.Fatalf("Error resolving %s: %v", loc, err)
+        }
+        if resolved != "https://www.example.com/Page" {
+            t.Fatalf("Expected %s to resolve to https://www.example.com/Page, got %s", loc, resolved)
+        }
+    }
+
+    // Differently cased hosts are merged by DeduplicateURLs
+    sm.DeduplicateURLs = true
+    sm.AddURL(SitemapURL{Loc: "HTTPS://Www.Example.com/Page", LastMod: "2023-01-01"})
+    sm.AddURL(SitemapURL{Loc: "https://www.example.com/Page", LastMod: "2023-06-01"})
+    sm.AddURL(SitemapURL{Loc: "https://www.example.com/page", LastMod: "2023-06-01"})
+    if _, err := sm.WriteToMemory(""); err != nil {
+        t.Fatalf("Error writing sitemap: %v", err)
+    }
+    if len(sm.prepared) != 2 {
+        t.Fatalf("Expected only the host case to be merged, got %d URLs", len(sm.prepared))
+    }
+}
+
+func TestShardsComputedOncePerRun(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_shards_once", "https://www.example.com")
+    sm.FS = NewMemFS()
+    sm.MaxURLs = 2
+    for i := 0; i < 5; i++ {
+        sm.AddURL(SitemapURL{Loc: "/page/" + strconv.Itoa(i), LastMod: "2023-10-25"})
+    }
+    if _, err := sm.WriteToMemory("https://www.example.com/sitemaps/"); err != nil {
+        t.Fatalf("Error writing sitemaps: %v", err)
+    }
+
+    // The run's split is reused, callers getting their own copy of it
+    first, second := sm.shards(), sm.shards()
+    if len(first) != 3 || &first[0].urls[0] != &second[0].urls[0] {
+        t.Fatalf("Expected the split of the run to be reused, got %d shards", len(first))
+    }
+    first[0].name = "changed.xml"
+    if sm.shards()[0].name != "sitemap_1.xml" {
+        t.Fatalf("Expected callers not to alter the cached split")
+    }
+
+    // A new run splits its own URLs
+    sm.AddURL(SitemapURL{Loc: "/page/5", LastMod: "2023-10-25"})
+    if _, err := sm.WriteToMemory("https://www.example.com/sitemaps/"); err != nil {
+        t.Fatalf("Error writing sitemaps: %v", err)
+    }
+    if shards := sm.shards(); len(shards) != 3 || len(shards[2].urls) != 2 {
+        t.Fatalf("Expected the new run to split its URLs again, got %+v", shards)
+    }
+}
@@ -0,0 +1,19 @@
+package nyxsitemap
+
+// SplitFile reads an existing sitemap file at filePath, e.g. one that has
+// outgrown the protocol limits, and rewrites its URLs into Dir as Write
+// would: shards of at most MaxURLs each plus a sitemap index referencing
+// them through baseSitemapURL. URLs are taken as they are in the file,
+// without the lastmod fixes of AddURL. URLs already added are left
+// untouched.
+func (s *SitemapOptions) SplitFile(filePath string, baseSitemapURL string) error {
+    _, urlSet, err := readURLSet(filePath)
+    if err != nil {
+        return err
+    }
+
+    urls := s.URLs
+    s.URLs = urlSet.URLs
+    defer func() { s.URLs = urls }()
+    return s.Write(baseSitemapURL)
+}
@@ -0,0 +1,54 @@
+package nyxsitemap
+
+import (
+    "os"
+    "path"
+    "strconv"
+    "strings"
+    "testing"
+)
+
+func TestSplitFile(t *testing.T) {
+    dir := "./test_sitemaps_split"
+    os.RemoveAll(dir)
+    defer os.RemoveAll(dir)
+
+    // An oversized single sitemap, written with a larger URL limit
+    oversized := NewSitemapOptions(path.Join(dir, "legacy"), "https://www.example.com")
+    oversized.MaxURLs = 100
+    for i := 0; i < 25; i++ {
+        oversized.AddURL(SitemapURL{Loc: "/page/" + strconv.Itoa(i), LastMod: "2023-10-25"})
+    }
+    if err := oversized.Write(""); err != nil {
+        t.Fatalf("Error writing oversized sitemap: %v", err)
+    }
+
+    sm := NewSitemapOptions(path.Join(dir, "split"), "https://www.example.com")
+    sm.MaxURLs = 10
+    if err := sm.SplitFile(path.Join(dir, "legacy", "sitemap.xml"), "https://www.example.com/sitemaps/"); err != nil {
+        t.Fatalf("Error splitting sitemap: %v", err)
+    }
+    if len(sm.URLs) != 0 {
+        t.Fatalf("Expected SplitFile to leave URLs untouched, got %d", len(sm.URLs))
+    }
+
+    total := 0
+    for i := 1; i <= 3; i++ {
+        name := path.Join(dir, "split", shardName(i))
+        if err := sm.validateXMLFile(name, false); err != nil {
+            t.Fatalf("Expected %s to be a valid shard: %v", name, err)
+        }
+        data, _ := os.ReadFile(name)
+        total += strings.Count(string(data), "<url>")
+    }
+    if total != 25 {
+        t.Fatalf("Expected all 25 URLs across the shards, got %d", total)
+    }
+    index, err := os.ReadFile(path.Join(dir, "split", "sitemap_index.xml"))
+    if err != nil {
+        t.Fatalf("Expected a sitemap index: %v", err)
+    }
+    if !strings.Contains(string(index), "https://www.example.com/sitemaps/sitemap_3.xml") {
+        t.Fatalf("Expected the index to reference all shards, got:\n%s", index)
+    }
+}
@@ -0,0 +1,62 @@
+package nyxsitemap
+
+import (
+    "encoding/xml"
+    "time"
+)
+
+// Stats summarizes the last Write.
+type Stats struct {
+    MarshalDuration  time.Duration // Encoding sitemaps and the index to XML
+    ValidateDuration time.Duration // Reading files back and validating them
+    WriteDuration    time.Duration // Writing files to the FileSystem
+    TotalDuration    time.Duration // The whole Write call
+
+    Images     int // image:image extensions emitted
+    Videos     int // video:video extensions emitted
+    Alternates int // xhtml:link alternates emitted, AMPURLs included
+}
+
+// Stats returns statistics about the last Write.
+func (s *SitemapOptions) Stats() Stats {
+    return s.stats
+}
+
+// add adds the durations and counts of other, e.g. a sub-run, to st.
+func (st *Stats) add(other Stats) {
+    st.MarshalDuration += other.MarshalDuration
+    st.ValidateDuration += other.ValidateDuration
+    st.WriteDuration += other.WriteDuration
+    st.Images += other.Images
+    st.Videos += other.Videos
+    st.Alternates += other.Alternates
+}
+
+// scaleDurations multiplies the phase durations of st by factor.
+func (st *Stats) scaleDurations(factor float64) {
+    st.MarshalDuration = time.Duration(float64(st.MarshalDuration) * factor)
+    st.ValidateDuration = time.Duration(float64(st.ValidateDuration) * factor)
+    st.WriteDuration = time.Duration(float64(st.WriteDuration) * factor)
+}
+
+// addSince adds the time elapsed since start to d.
+func addSince(d *time.Duration, start time.Time) {
+    *d += time.Since(start)
+}
+
+// countExtensions adds the images, videos and alternates of urls, just
+// written to a sitemap file, to the stats.
+func (s *SitemapOptions) countExtensions(urls []SitemapURL) {
+    for _, u := range withFieldExtensions(urls) {
+        for _, ext := range u.Extensions {
+            switch ext.XMLName {
+            case xml.Name{Space: imageNamespace, Local: "image"}:
+                s.stats.Images++
+            case xml.Name{Space: videoNamespace, Local: "video"}:
+                s.stats.Videos++
+            case xml.Name{Space: xhtmlNamespace, Local: "link"}:
+                s.stats.Alternates++
+            }
+        }
+    }
+}
@@ -0,0 +1,73 @@
+package nyxsitemap
+
+import (
+    "encoding/xml"
+    "os"
+    "strconv"
+    "testing"
+)
+
+func TestStatsDurations(t *testing.T) {
+    dir := "./test_sitemaps_stats"
+    os.RemoveAll(dir)
+    defer os.RemoveAll(dir)
+
+    sm := NewSitemapOptions(dir, "https://www.example.com")
+    sm.MaxURLs = 1000
+    for i := 0; i < 3000; i++ {
+        sm.AddURL(SitemapURL{Loc: "/page/" + strconv.Itoa(i)})
+    }
+
+    if err := sm.Write("https://www.example.com/"); err != nil {
+        t.Fatalf("Error writing sitemaps: %v", err)
+    }
+
+    // The phases are measured within the whole Write
+    stats := sm.Stats()
+    if stats.MarshalDuration <= 0 || stats.ValidateDuration <= 0 || stats.WriteDuration <= 0 {
+        t.Fatalf("Expected all phase durations to be populated: %+v", stats)
+    }
+    sum := stats.MarshalDuration + stats.ValidateDuration + stats.WriteDuration
+    if sum > stats.TotalDuration {
+        t.Fatalf("Phase durations %v exceed total %v", sum, stats.TotalDuration)
+    }
+}
+
+func TestStatsExtensionCounts(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_stats_counts", "https://www.example.com")
+    sm.FS = NewMemFS()
+    sm.MaxURLs = 1
+    image := func(loc string) Extension {
+        return Extension{
+            XMLName:  xml.Name{Space: imageNamespace, Local: "image"},
+            Children: []Extension{{XMLName: xml.Name{Space: imageNamespace, Local: "loc"}, Value: loc}},
+        }
+    }
+    alternate := Extension{
+        XMLName: xml.Name{Space: xhtmlNamespace, Local: "link"},
+        Attrs: []xml.Attr{
+            {Name: xml.Name{Local: "rel"}, Value: "alternate"},
+            {Name: xml.Name{Local: "hreflang"}, Value: "fr"},
+            {Name: xml.Name{Local: "href"}, Value: "https://www.example.com/fr/"},
+        },
+    }
+    sm.AddURL(SitemapURL{Loc: "/", LastMod: "2023-10-25", Extensions: []Extension{
+        image("https://www.example.com/a.png"), image("https://www.example.com/b.png"), alternate,
+    }})
+    sm.AddURL(SitemapURL{Loc: "/amp-page", LastMod: "2023-10-25", AMPURL: "/amp/page"})
+
+    if err := sm.Write("https://www.example.com/"); err != nil {
+        t.Fatalf("Error writing sitemaps: %v", err)
+    }
+    stats := sm.Stats()
+    if stats.Images != 2 || stats.Videos != 0 || stats.Alternates != 2 {
+        t.Fatalf("Expected 2 images, 0 videos and 2 alternates, got %+v", stats)
+    }
+
+    if err := sm.Write("https://www.example.com/"); err != nil {
+        t.Fatalf("Error writing sitemaps: %v", err)
+    }
+    if stats := sm.Stats(); stats.Images != 2 {
+        t.Fatalf("Expected counts to be reset between runs, got %+v", stats)
+    }
+}
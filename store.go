@@ -0,0 +1,31 @@
+package nyxsitemap
+
+import "fmt"
+
+// ObjectStore is an object storage bucket, e.g. S3, that generated files
+// can be uploaded to.
+type ObjectStore interface {
+    Put(key string, data []byte, contentType, contentEncoding string) error
+}
+
+// WriteToStore generates the sitemap files in memory, like WriteToMemory,
+// and uploads each of them to store keyed by its name relative to Dir, with
+// the Content-Type it would be served with. Gzipped files are uploaded with
+// a gzip Content-Encoding.
+func (s *SitemapOptions) WriteToStore(store ObjectStore, baseSitemapURL string) error {
+    files, err := s.WriteToMemory(baseSitemapURL)
+    if err != nil {
+        return err
+    }
+    for _, file := range s.files {
+        data := files[file.Name]
+        contentEncoding := ""
+        if isGzip(data) {
+            contentEncoding = "gzip"
+        }
+        if err := store.Put(file.Name, data, file.Headers["Content-Type"], contentEncoding); err != nil {
+            return fmt.Errorf("failed to upload '%s': %v", file.Name, err)
+        }
+    }
+    return nil
+}
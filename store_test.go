@@ -0,0 +1,55 @@
+package nyxsitemap
+
+import (
+    "bytes"
+    "testing"
+)
+
+// memObject is an object uploaded to memStore.
+type memObject struct {
+    data            []byte
+    contentType     string
+    contentEncoding string
+}
+
+// memStore is an in-memory ObjectStore.
+type memStore map[string]memObject
+
+func (m memStore) Put(key string, data []byte, contentType, contentEncoding string) error {
+    m[key] = memObject{data: data, contentType: contentType, contentEncoding: contentEncoding}
+    return nil
+}
+
+func TestWriteToStore(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_store", "https://www.example.com")
+    sm.MaxURLs = 1
+    sm.AddURL(SitemapURL{Loc: "/a", LastMod: "2023-10-25"})
+    sm.AddURL(SitemapURL{Loc: "/b", LastMod: "2023-10-25"})
+
+    store := memStore{}
+    if err := sm.WriteToStore(store, "https://www.example.com/sitemaps/"); err != nil {
+        t.Fatalf("Error writing sitemaps to store: %v", err)
+    }
+
+    expected := map[string]string{
+        "sitemap.xsl":       "text/xsl",
+        "sitemap_1.xml":     "application/xml",
+        "sitemap_2.xml":     "application/xml",
+        "sitemap_index.xml": "application/xml",
+    }
+    if len(store) != len(expected) {
+        t.Fatalf("Expected %d objects, got %d", len(expected), len(store))
+    }
+    for key, contentType := range expected {
+        object, ok := store[key]
+        if !ok {
+            t.Fatalf("Expected %s to be uploaded", key)
+        }
+        if object.contentType != contentType || object.contentEncoding != "" {
+            t.Fatalf("Unexpected metadata for %s: %q, %q", key, object.contentType, object.contentEncoding)
+        }
+    }
+    if !bytes.Contains(store["sitemap_index.xml"].data, []byte("sitemap_2.xml")) {
+        t.Fatalf("Expected the uploaded index to reference the shards")
+    }
+}
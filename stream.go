@@ -0,0 +1,198 @@
+package nyxsitemap
+
+import (
+    "context"
+    "fmt"
+    "path"
+    "time"
+)
+
+// URLSource supplies URLs one at a time, e.g. from a database cursor.
+// Next returns false once the source is exhausted.
+type URLSource interface {
+    Next() (SitemapURL, bool, error)
+}
+
+// WriteSource generates sitemap files from src without materializing it into
+// URLs: at most one shard's worth of URLs is held in memory at a time, so
+// memory stays bounded however many URLs src yields. URLs get the same
+// fixes as AddURL. A shard is rolled over once it holds MaxURLs URLs, or
+// 50,000 in the Adaptive SplitMode, or the next URL would take it past
+// MaxFileSize. A single sitemap.xml is written when the source fits in one
+// file under the index threshold, shards plus an index otherwise.
+// DirLayout, DeduplicateURLs, CheckGlobalUniqueness, MaxTotalBytes,
+// DropInvalidShards and the sorting of Canonical need the whole URL set and
+// are not applied: URLs are written in the order src yields them.
+func (s *SitemapOptions) WriteSource(src URLSource, baseSitemapURL string) error {
+    s.resetRun()
+    defer s.endRun(time.Now())
+
+    if err := s.writeSource(src, baseSitemapURL); err != nil {
+        return err
+    }
+    if err := s.mirrorFiles(); err != nil {
+        return err
+    }
+    return s.recordTopURL(baseSitemapURL)
+}
+
+// channelSource is a URLSource receiving from a channel until ctx is done.
+type channelSource struct {
+    ctx  context.Context
+    urls <-chan SitemapURL
+}
+
+func (src channelSource) Next() (SitemapURL, bool, error) {
+    select {
+    case u, ok := <-src.urls:
+        return u, ok, nil
+    case <-src.ctx.Done():
+        return SitemapURL{}, false, src.ctx.Err()
+    }
+}
+
+// WriteStream is WriteSource over URLs sent on a channel by a concurrent
+// producer, which closes it when done. URLs are only received as shards get
+// written, so the channel's capacity bounds how far a fast producer can run
+// ahead: it blocks once the buffer is full. Cancelling ctx stops the write.
+// If writing fails, the remaining URLs are drained in the background so the
+// producer doesn't block forever, until the channel is closed or ctx is
+// done: a producer that does neither must watch ctx itself.
+func (s *SitemapOptions) WriteStream(ctx context.Context, urls <-chan SitemapURL, baseSitemapURL string) error {
+    err := s.WriteSource(channelSource{ctx: ctx, urls: urls}, baseSitemapURL)
+    if err != nil {
+        go func() {
+            for {
+                select {
+                case _, ok := <-urls:
+                    if !ok {
+                        return
+                    }
+                case <-ctx.Done():
+                    return
+                }
+            }
+        }()
+    }
+    return err
+}
+
+// writeSource generates and validates the sitemap files from src in Dir.
+func (s *SitemapOptions) writeSource(src URLSource, baseSitemapURL string) error {
+    // Ensure the directory exists
+    if err := s.ensureDir(); err != nil {
+        return err
+    }
+
+    // Write the stylesheet into the sitemap directory
+    if s.IncludeStylesheet {
+        if err := s.writeStylesheet(); err != nil {
+            return err
+        }
+    }
+
+    covered, err := s.extensionSchemas()
+    if err != nil {
+        return err
+    }
+
+    maxURLs, threshold := s.MaxURLs, s.indexThreshold()
+    if s.SplitMode == Adaptive {
+        maxURLs, threshold = protocolMaxURLs, s.IndexThreshold
+    }
+    empty, err := s.marshalURLSet("sitemap.xml", nil)
+    if err != nil {
+        return err
+    }
+
+    var entries []indexEntry
+    names := map[string]bool{}
+    batch := make([]SitemapURL, 0, maxURLs)
+    size := len(empty)
+
+    // flush writes and validates the buffered URLs as the next shard
+    flush := func() error {
+        name := s.ShardName("", len(entries)+1, batch)
+        if s.ShardFilename != nil {
+            if err := s.checkShardName(name); err != nil {
+                return err
+            }
+            if names[name] {
+                return fmt.Errorf("shard filename '%s' is used by several shards", name)
+            }
+            names[name] = true
+        }
+        if err := s.writeSitemapFile(name, batch); err != nil {
+            return err
+        }
+        if err := s.validateXMLFile(path.Join(s.Dir, name), false); err != nil {
+            return err
+        }
+        entry, err := s.newIndexEntry(baseSitemapURL, name, batch)
+        if err != nil {
+            return err
+        }
+        entries = append(entries, entry)
+        batch = batch[:0]
+        size = len(empty)
+        return nil
+    }
+
+    isIndex := false
+    for {
+        u, ok, err := src.Next()
+        if err != nil {
+            return fmt.Errorf("failed to read URL from source: %v", err)
+        }
+        if !ok {
+            break
+        }
+
+        u = s.normalizeURL(u)
+        if err := s.prepareURL(&u); err != nil {
+            return err
+        }
+        u, ok = s.transformURL(u)
+        if !ok {
+            continue
+        }
+        if err := checkExtensionSchema(u, covered); err != nil {
+            return err
+        }
+        s.checkURL(u)
+
+        cost := s.urlCost("sitemap.xml", len(empty), u)
+        full := len(batch) == maxURLs || (len(batch) > 0 && s.overFileSize(size+cost))
+
+        // Exceeding the threshold or needing a second file means the output
+        // becomes an index
+        if !isIndex && (full || (threshold > 0 && len(batch) == threshold)) {
+            if err := checkBaseSitemapURL(baseSitemapURL); err != nil {
+                return err
+            }
+            isIndex = true
+        }
+        if full {
+            if err := flush(); err != nil {
+                return err
+            }
+        }
+        batch = append(batch, u)
+        size += cost
+    }
+
+    if !isIndex {
+        if err := s.writeSitemapFile(s.singleName(), batch); err != nil {
+            return err
+        }
+        return s.validateXMLFile(path.Join(s.Dir, s.singleName()), false)
+    }
+
+    if err := flush(); err != nil {
+        return err
+    }
+    if err := s.writeIndexFile(entries, baseSitemapURL); err != nil {
+        return err
+    }
+    return s.validateXMLFile(path.Join(s.Dir, s.indexName()), true)
+}
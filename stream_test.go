@@ -0,0 +1,197 @@
+package nyxsitemap
+
+import (
+    "bytes"
+    "context"
+    "os"
+    "path"
+    "strconv"
+    "strings"
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+// sliceSource is a URLSource over an in-memory list.
+type sliceSource struct {
+    urls []SitemapURL
+    next int
+}
+
+func (src *sliceSource) Next() (SitemapURL, bool, error) {
+    if src.next >= len(src.urls) {
+        return SitemapURL{}, false, nil
+    }
+    u := src.urls[src.next]
+    src.next++
+    return u, true, nil
+}
+
+func TestWriteSourceMatchesWrite(t *testing.T) {
+    sourceDir := "./test_sitemaps_source"
+    writeDir := "./test_sitemaps_source_write"
+    baseSitemapURL := "https://www.example.com/sitemaps/"
+    os.RemoveAll(sourceDir)
+    os.RemoveAll(writeDir)
+    defer os.RemoveAll(sourceDir)
+    defer os.RemoveAll(writeDir)
+
+    var urls []SitemapURL
+    for i := 0; i < 25; i++ {
+        urls = append(urls, SitemapURL{
+            Loc:        "/page/" + strconv.Itoa(i),
+            LastMod:    "2023-10-25",
+            ChangeFreq: "weekly",
+        })
+    }
+
+    streamed := NewSitemapOptions(sourceDir, "https://www.example.com")
+    streamed.MaxURLs = 10
+    if err := streamed.WriteSource(&sliceSource{urls: urls}, baseSitemapURL); err != nil {
+        t.Fatalf("Error writing sitemaps from source: %v", err)
+    }
+
+    expected := NewSitemapOptions(writeDir, "https://www.example.com")
+    expected.MaxURLs = 10
+    expected.AddURLs(urls)
+    if err := expected.Write(baseSitemapURL); err != nil {
+        t.Fatalf("Error writing sitemaps: %v", err)
+    }
+
+    for _, name := range []string{"sitemap_index.xml", "sitemap_1.xml", "sitemap_2.xml", "sitemap_3.xml"} {
+        got, err := os.ReadFile(path.Join(sourceDir, name))
+        if err != nil {
+            t.Fatalf("Expected %s to be written from source: %v", name, err)
+        }
+        want, _ := os.ReadFile(path.Join(writeDir, name))
+        if !bytes.Equal(got, want) {
+            t.Fatalf("%s from source does not match Write output:\n%s", name, got)
+        }
+    }
+}
+
+func TestWriteSourceSingleFile(t *testing.T) {
+    dir := "./test_sitemaps_source_single"
+    os.RemoveAll(dir)
+    defer os.RemoveAll(dir)
+
+    sm := NewSitemapOptions(dir, "https://www.example.com")
+    src := &sliceSource{urls: []SitemapURL{{Loc: "/"}, {Loc: "/about"}}}
+    if err := sm.WriteSource(src, ""); err != nil {
+        t.Fatalf("Error writing sitemap from source: %v", err)
+    }
+    if _, err := os.Stat(path.Join(dir, "sitemap.xml")); err != nil {
+        t.Fatalf("Expected sitemap.xml to be written: %v", err)
+    }
+    if _, err := os.Stat(path.Join(dir, "sitemap_index.xml")); !os.IsNotExist(err) {
+        t.Fatalf("Expected no sitemap index for a small source")
+    }
+}
+
+// slowFS delays every write and records how many URLs the producer had sent
+// at the time.
+type slowFS struct {
+    *MemFS
+    sent    *int64
+    maxSent []int64
+}
+
+func (fs *slowFS) WriteFile(name string, data []byte, perm os.FileMode) error {
+    time.Sleep(5 * time.Millisecond)
+    fs.maxSent = append(fs.maxSent, atomic.LoadInt64(fs.sent))
+    return fs.MemFS.WriteFile(name, data, perm)
+}
+
+func TestWriteStreamBackpressure(t *testing.T) {
+    var sent int64
+    fs := &slowFS{MemFS: NewMemFS(), sent: &sent}
+    sm := NewSitemapOptions("test_sitemaps_stream", "https://www.example.com")
+    sm.FS = fs
+    sm.MaxURLs = 10
+    sm.IncludeStylesheet = false
+
+    const total, buffer = 100, 5
+    urls := make(chan SitemapURL, buffer)
+    go func() {
+        for i := 0; i < total; i++ {
+            urls <- SitemapURL{Loc: "/page/" + strconv.Itoa(i), LastMod: "2023-10-25"}
+            atomic.AddInt64(&sent, 1)
+        }
+        close(urls)
+    }()
+
+    if err := sm.WriteStream(context.Background(), urls, "https://www.example.com/sitemaps/"); err != nil {
+        t.Fatalf("Error writing sitemaps from stream: %v", err)
+    }
+
+    // When shard n is written, the producer is at most one shard plus the
+    // channel buffer ahead of it
+    for n, sentAt := range fs.maxSent[:len(fs.maxSent)-1] {
+        if limit := int64((n+1)*sm.MaxURLs + 1 + buffer + 1); sentAt > limit {
+            t.Fatalf("Producer ran %d URLs ahead when writing shard %d, limit %d", sentAt, n+1, limit)
+        }
+    }
+    count := 0
+    for i := 1; i <= total/sm.MaxURLs; i++ {
+        data, err := fs.ReadFile("test_sitemaps_stream/" + shardName(i))
+        if err != nil {
+            t.Fatalf("Expected shard %d to be written: %v", i, err)
+        }
+        count += bytes.Count(data, []byte("<url>"))
+    }
+    if count != total {
+        t.Fatalf("Expected all %d URLs to be written, got %d", total, count)
+    }
+}
+
+func TestWriteStreamCancel(t *testing.T) {
+    sm := NewSitemapOptions("test_sitemaps_stream_cancel", "https://www.example.com")
+    sm.FS = NewMemFS()
+
+    // A producer that never closes its channel doesn't hold the write, nor
+    // the drain, past ctx
+    urls := make(chan SitemapURL)
+    ctx, cancel := context.WithCancel(context.Background())
+    go func() {
+        urls <- SitemapURL{Loc: "/a", LastMod: "2023-10-25"}
+        cancel()
+    }()
+    if err := sm.WriteStream(ctx, urls, "https://www.example.com/sitemaps/"); err == nil || !strings.Contains(err.Error(), "canceled") {
+        t.Fatalf("Expected the cancelled stream to fail, got: %v", err)
+    }
+}
+
+func TestWriteSourceRollsOverAtMaxFileSize(t *testing.T) {
+    for _, mode := range []SplitMode{FixedCount, Adaptive} {
+        sm := NewSitemapOptions("./test_sitemaps_stream_size", "https://www.example.com")
+        sm.FS = NewMemFS()
+        sm.SplitMode = mode
+        sm.MaxFileSize = 2048
+
+        var urls []SitemapURL
+        for i := 0; i < 60; i++ {
+            urls = append(urls, SitemapURL{Loc: "/page/" + strconv.Itoa(i), LastMod: "2023-10-25"})
+        }
+        if err := sm.WriteSource(&sliceSource{urls: urls}, "https://www.example.com/"); err != nil {
+            t.Fatalf("Error writing sitemaps from source in mode %d: %v", mode, err)
+        }
+
+        shards, count := 0, 0
+        for _, file := range sm.Files() {
+            if file.URLCount == 0 {
+                continue
+            }
+            if file.Size > sm.MaxFileSize {
+                t.Fatalf("Shard %s of %d bytes exceeds MaxFileSize in mode %d", file.Name, file.Size, mode)
+            }
+            shards++
+            count += file.URLCount
+        }
+        if shards < 2 || count != len(urls) {
+            t.Fatalf("Expected %d URLs across several shards in mode %d, got %d in %d", len(urls), mode, count, shards)
+        }
+        if sm.Files()[len(sm.Files())-1].Name != "sitemap_index.xml" {
+            t.Fatalf("Expected an index after rolling over in mode %d", mode)
+        }
+    }
+}
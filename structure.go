@@ -0,0 +1,87 @@
+package nyxsitemap
+
+import (
+    "encoding/xml"
+    "fmt"
+    "net/url"
+    "strconv"
+)
+
+// sitemapNamespace is the namespace of urlset and sitemapindex documents.
+const sitemapNamespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+// changeFreqs are the changefreq values allowed by the sitemap protocol.
+var changeFreqs = map[string]bool{
+    "always":  true,
+    "hourly":  true,
+    "daily":   true,
+    "weekly":  true,
+    "monthly": true,
+    "yearly":  true,
+    "never":   true,
+}
+
+// ValidateStructure checks a sitemap, or sitemap index if isIndex is true,
+// in pure Go: the root element and namespace, that every loc is an absolute
+// http(s) URL of at most 2,048 characters, and that lastmods are W3C
+// datetimes, changefreqs are protocol values and priorities lie in
+// [0.0, 1.0]. It is a lighter alternative to the XSDs for builds without
+// cgo, e.g. as Validator; extension elements aren't checked.
+func ValidateStructure(data []byte, isIndex bool) error {
+    if isIndex {
+        var index SitemapIndex
+        if err := xml.Unmarshal(data, &index); err != nil {
+            return fmt.Errorf("failed to parse XML: %v", err)
+        }
+        if index.XMLName.Space != sitemapNamespace {
+            return fmt.Errorf("sitemapindex namespace is '%s', expected '%s'", index.XMLName.Space, sitemapNamespace)
+        }
+        for i, sitemap := range index.Sitemaps {
+            if err := checkStructure(sitemap.Loc, sitemap.LastMod, "", ""); err != nil {
+                return fmt.Errorf("sitemap %d: %v", i+1, err)
+            }
+        }
+        return nil
+    }
+
+    var urlSet URLSet
+    if err := xml.Unmarshal(data, &urlSet); err != nil {
+        return fmt.Errorf("failed to parse XML: %v", err)
+    }
+    if urlSet.XMLName.Space != sitemapNamespace {
+        return fmt.Errorf("urlset namespace is '%s', expected '%s'", urlSet.XMLName.Space, sitemapNamespace)
+    }
+    for i, u := range urlSet.URLs {
+        if err := checkStructure(u.Loc, u.LastMod, u.ChangeFreq, u.Priority); err != nil {
+            return fmt.Errorf("URL %d: %v", i+1, err)
+        }
+    }
+    return nil
+}
+
+// checkStructure checks the fields of a url or sitemap entry.
+func checkStructure(loc, lastMod, changeFreq, priority string) error {
+    if loc == "" {
+        return fmt.Errorf("missing loc")
+    }
+    if len(loc) > 2048 {
+        return fmt.Errorf("loc '%s' is longer than 2048 characters", loc)
+    }
+    if u, err := url.Parse(loc); err != nil || !u.IsAbs() || (u.Scheme != "http" && u.Scheme != "https") {
+        return fmt.Errorf("loc '%s' is not an absolute http(s) URL", loc)
+    }
+    if lastMod != "" {
+        if _, err := parseW3CDate(lastMod); err != nil {
+            return fmt.Errorf("invalid lastmod for '%s': %v", loc, err)
+        }
+    }
+    if changeFreq != "" && !changeFreqs[changeFreq] {
+        return fmt.Errorf("invalid changefreq '%s' for '%s'", changeFreq, loc)
+    }
+    if priority != "" {
+        if value, err := strconv.ParseFloat(priority, 64); err != nil || value < 0 || value > 1 {
+            return fmt.Errorf("invalid priority '%s' for '%s': must be a decimal between 0.0 and 1.0", priority, loc)
+        }
+    }
+    return nil
+}
@@ -0,0 +1,67 @@
+package nyxsitemap
+
+import (
+    "strings"
+    "testing"
+)
+
+func TestValidateStructure(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_structure", "https://www.example.com")
+    sm.MaxURLs = 1
+    sm.AddURL(SitemapURL{Loc: "/a", LastMod: "2023-10-25", ChangeFreq: "daily", Priority: "0.8"})
+    sm.AddURL(SitemapURL{Loc: "/b", LastMod: "2023-10-25T10:00:00Z"})
+
+    files, err := sm.WriteToMemory("https://www.example.com/")
+    if err != nil {
+        t.Fatalf("Error writing sitemaps to memory: %v", err)
+    }
+    if err := ValidateStructure(files["sitemap_index.xml"], true); err != nil {
+        t.Fatalf("Expected the index to pass: %v", err)
+    }
+    if err := ValidateStructure(files["sitemap_1.xml"], false); err != nil {
+        t.Fatalf("Expected the urlset to pass: %v", err)
+    }
+    if err := ValidateStructure(files["sitemap_1.xml"], true); err == nil {
+        t.Fatalf("Expected a urlset to fail as an index")
+    }
+
+    for _, tc := range []struct{ old, new, expected string }{
+        {"<loc>https://www.example.com/a</loc>", "<loc></loc>", "missing loc"},
+        {"<loc>https://www.example.com/a</loc>", "<loc>/a</loc>", "not an absolute"},
+        {"daily", "sometimes", "changefreq"},
+        {"0.8", "1.5", "priority"},
+        {"2023-10-25", "25/10/2023", "lastmod"},
+        {sitemapNamespace, "http://example.com/ns", "namespace"},
+    } {
+        data := strings.Replace(string(files["sitemap_1.xml"]), tc.old, tc.new, 1)
+        if err := ValidateStructure([]byte(data), false); err == nil || !strings.Contains(err.Error(), tc.expected) {
+            t.Fatalf("Expected replacing %s with %s to fail on %s, got: %v", tc.old, tc.new, tc.expected, err)
+        }
+    }
+}
+
+func TestValidateOption(t *testing.T) {
+    // Still well-formed, but the changefreq enumeration rejects it
+    fs := &tamperFS{MemFS: NewMemFS(), old: "daily", new: "sometimes"}
+    sm := NewSitemapOptions("./test_sitemaps_validate_option", "https://www.example.com")
+    sm.FS = fs
+    sm.AddURL(SitemapURL{Loc: "/a", LastMod: "2023-10-25", ChangeFreq: "daily"})
+
+    if err := sm.Write(""); err == nil {
+        t.Fatalf("Expected validation to be enabled by default")
+    }
+
+    sm.Validator = ValidateStructure
+    if err := sm.Write(""); err == nil || !strings.Contains(err.Error(), "changefreq") {
+        t.Fatalf("Expected the structural Validator to reject the file, got: %v", err)
+    }
+
+    sm.Validate = false
+    for _, maxURLs := range []int{10, 1} {
+        sm.MaxURLs = maxURLs
+        sm.AddURL(SitemapURL{Loc: "/b", LastMod: "2023-10-25"})
+        if err := sm.Write("https://www.example.com/"); err != nil {
+            t.Fatalf("Expected Write to skip validation with MaxURLs %d: %v", maxURLs, err)
+        }
+    }
+}
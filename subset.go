@@ -0,0 +1,109 @@
+package nyxsitemap
+
+import (
+    "fmt"
+    "path"
+    "sort"
+    "strconv"
+    "time"
+)
+
+// WriteSample writes the n highest-priority URLs to a single sitemap file at
+// filePath, breaking ties by the newest lastmod. URLs without a priority
+// count as 0.5. It's meant for QA, e.g. load-testing a crawler against a
+// representative subset, not for production sitemaps. URLs are prepared
+// as Write prepares them. With Gzip the file is gzipped, ".gz" being added
+// to filePath unless GzipKeepExtension is set.
+func (s *SitemapOptions) WriteSample(n int, filePath string) error {
+    if n <= 0 {
+        return fmt.Errorf("sample size must be positive, got %d", n)
+    }
+    sub, err := s.preparedCopy()
+    if err != nil {
+        return err
+    }
+    urls := sub.prepared
+
+    sort.SliceStable(urls, func(i, j int) bool {
+        pi, pj := priorityValue(urls[i].Priority), priorityValue(urls[j].Priority)
+        if pi != pj {
+            return pi > pj
+        }
+        return urls[i].LastMod > urls[j].LastMod
+    })
+    if n < len(urls) {
+        urls = urls[:n]
+    }
+
+    return s.writeSubset(filePath, urls)
+}
+
+// WriteRange writes the URLs whose lastmod falls within [from, to] to a
+// single sitemap file at filePath, e.g. to resubmit recently changed
+// content. Date-only lastmods count as midnight UTC; URLs with an
+// unparseable lastmod are left out. URLs are prepared and the file gzipped
+// as with WriteSample.
+func (s *SitemapOptions) WriteRange(from, to time.Time, filePath string) error {
+    sub, err := s.preparedCopy()
+    if err != nil {
+        return err
+    }
+
+    var inRange []SitemapURL
+    for _, u := range sub.prepared {
+        lastMod, err := parseW3CDate(u.LastMod)
+        if err != nil || lastMod.Before(from) || lastMod.After(to) {
+            continue
+        }
+        inRange = append(inRange, u)
+    }
+
+    return s.writeSubset(filePath, inRange)
+}
+
+// writeSubset writes urls as a standalone sitemap file at filePath and
+// validates it. An empty subset is rejected, as the schema requires at
+// least one URL.
+func (s *SitemapOptions) writeSubset(filePath string, urls []SitemapURL) error {
+    if len(urls) == 0 {
+        return fmt.Errorf("no URLs to write to '%s'", filePath)
+    }
+    filePath = s.gzipName(filePath, s.Gzip)
+    data, err := s.marshalURLSet(path.Base(filePath), urls)
+    if err != nil {
+        return err
+    }
+    if data, err = encodeFile(filePath, data, s.Gzip); err != nil {
+        return err
+    }
+    if err := s.fs().MkdirAll(path.Dir(filePath), 0755); err != nil {
+        return err
+    }
+    if err := s.fs().WriteFile(filePath, data, 0644); err != nil {
+        return err
+    }
+    return s.validateXMLFile(filePath, false)
+}
+
+// resolvedURLs returns a copy of URLs with every loc resolved against BaseURL.
+func (s *SitemapOptions) resolvedURLs() ([]SitemapURL, error) {
+    urls := make([]SitemapURL, len(s.URLs))
+    for i, u := range s.URLs {
+        fullURL, err := s.resolveURL(u.Loc)
+        if err != nil {
+            return nil, err
+        }
+        u.Loc = fullURL
+        urls[i] = u
+    }
+    return urls, nil
+}
+
+// priorityValue parses a priority, defaulting to 0.5 as crawlers do.
+func priorityValue(priority string) float64 {
+    value, err := strconv.ParseFloat(priority, 64)
+    if err != nil {
+        return 0.5
+    }
+    return value
+}
@@ -0,0 +1,166 @@
+package nyxsitemap
+
+import (
+    "encoding/xml"
+    "os"
+    "path"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestWriteSample(t *testing.T) {
+    dir := "./test_sitemaps_sample"
+    os.RemoveAll(dir)
+    defer os.RemoveAll(dir)
+
+    sm := NewSitemapOptions(dir, "https://www.example.com")
+    sm.AddURL(SitemapURL{Loc: "/low", Priority: "0.1"})
+    sm.AddURL(SitemapURL{Loc: "/top", Priority: "1.0"})
+    sm.AddURL(SitemapURL{Loc: "/default"})
+    sm.AddURL(SitemapURL{Loc: "/older", Priority: "0.8", LastMod: "2022-01-01"})
+    sm.AddURL(SitemapURL{Loc: "/newer", Priority: "0.8", LastMod: "2023-01-01"})
+
+    samplePath := path.Join(dir, "sample.xml")
+    if err := sm.WriteSample(3, samplePath); err != nil {
+        t.Fatalf("Error writing sample: %v", err)
+    }
+
+    data, err := os.ReadFile(samplePath)
+    if err != nil {
+        t.Fatalf("Error reading sample: %v", err)
+    }
+    var urlSet URLSet
+    if err := xml.Unmarshal(data, &urlSet); err != nil {
+        t.Fatalf("Error parsing sample: %v", err)
+    }
+
+    expected := []string{
+        "https://www.example.com/top",
+        "https://www.example.com/newer",
+        "https://www.example.com/older",
+    }
+    if len(urlSet.URLs) != len(expected) {
+        t.Fatalf("Expected %d URLs in sample, got %d", len(expected), len(urlSet.URLs))
+    }
+    for i, loc := range expected {
+        if urlSet.URLs[i].Loc != loc {
+            t.Fatalf("Expected %s at position %d, got %s", loc, i, urlSet.URLs[i].Loc)
+        }
+    }
+}
+
+func TestWriteRange(t *testing.T) {
+    fs := NewMemFS()
+    sm := NewSitemapOptions("./test_sitemaps_range", "https://www.example.com")
+    sm.FS = fs
+    sm.AddURL(SitemapURL{Loc: "/old", LastMod: "2023-01-15"})
+    sm.AddURL(SitemapURL{Loc: "/start", LastMod: "2023-03-01"})
+    sm.AddURL(SitemapURL{Loc: "/middle", LastMod: "2023-03-15"})
+    sm.AddURL(SitemapURL{Loc: "/end", LastMod: "2023-03-31"})
+    sm.AddURL(SitemapURL{Loc: "/new", LastMod: "2023-06-01"})
+
+    from := time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)
+    to := time.Date(2023, 3, 31, 0, 0, 0, 0, time.UTC)
+    if err := sm.WriteRange(from, to, "recent/range.xml"); err != nil {
+        t.Fatalf("Error writing range: %v", err)
+    }
+
+    data, err := fs.ReadFile("recent/range.xml")
+    if err != nil {
+        t.Fatalf("Error reading range: %v", err)
+    }
+    var urlSet URLSet
+    if err := xml.Unmarshal(data, &urlSet); err != nil {
+        t.Fatalf("Error parsing range: %v", err)
+    }
+
+    expected := []string{
+        "https://www.example.com/start",
+        "https://www.example.com/middle",
+        "https://www.example.com/end",
+    }
+    if len(urlSet.URLs) != len(expected) {
+        t.Fatalf("Expected %d URLs in range, got %d", len(expected), len(urlSet.URLs))
+    }
+    for i, loc := range expected {
+        if urlSet.URLs[i].Loc != loc {
+            t.Fatalf("Expected URL %d to be %s, got %s", i, loc, urlSet.URLs[i].Loc)
+        }
+    }
+
+    // The range is prepared and gzipped as Write's files are
+    sm.Gzip = true
+    sm.CanonicalHost = "https://www.example.org"
+    if err := sm.WriteRange(from, to, "recent/range.xml"); err != nil {
+        t.Fatalf("Error writing gzipped range: %v", err)
+    }
+    raw, err := fs.ReadFile("recent/range.xml.gz")
+    if err != nil || !isGzip(raw) {
+        t.Fatalf("Expected a gzipped range, got %v", err)
+    }
+    data, _ = gunzipIfNeeded(raw)
+    if !strings.Contains(string(data), "<loc>https://www.example.org/middle</loc>") {
+        t.Fatalf("Expected CanonicalHost to apply to the range, got:\n%s", data)
+    }
+}
+
+func TestWriteSubsetRejectsEmpty(t *testing.T) {
+    fs := NewMemFS()
+    sm := NewSitemapOptions("./test_sitemaps_subset_empty", "https://www.example.com")
+    sm.FS = fs
+    sm.AddURL(SitemapURL{Loc: "/a", LastMod: "2023-01-15"})
+
+    for _, n := range []int{-1, 0} {
+        if err := sm.WriteSample(n, "sample.xml"); err == nil || !strings.Contains(err.Error(), "must be positive") {
+            t.Fatalf("Expected a sample size of %d to be rejected, got: %v", n, err)
+        }
+    }
+    from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+    err := sm.WriteRange(from, from.AddDate(1, 0, 0), "range.xml")
+    if err == nil || !strings.Contains(err.Error(), "no URLs to write") {
+        t.Fatalf("Expected an empty range to be rejected, got: %v", err)
+    }
+    if names := fs.Names(); len(names) != 0 {
+        t.Fatalf("Expected nothing to be written, got %v", names)
+    }
+}
+
+func TestWriteSamplePrepared(t *testing.T) {
+    fs := NewMemFS()
+    sm := NewSitemapOptions("./test_sitemaps_sample_prepared", "http://staging.example.com")
+    sm.FS = fs
+    sm.Gzip = true
+    sm.CanonicalHost = "https://www.example.com"
+    sm.AddURL(SitemapURL{
+        Loc:        "/gallery",
+        LastMod:    "2023-10-25",
+        Images:     []SitemapImage{{Loc: "/1.jpg"}},
+        Alternates: []Alternate{{Hreflang: "fr", Href: "/fr/gallery"}},
+    })
+
+    // The sample holds what Write would emit, gzipped alike
+    if err := sm.WriteSample(1, "test_sitemaps_sample_prepared/sample.xml"); err != nil {
+        t.Fatalf("Error writing sample: %v", err)
+    }
+    raw, err := fs.ReadFile("test_sitemaps_sample_prepared/sample.xml.gz")
+    if err != nil {
+        t.Fatalf("Expected a gzipped sample: %v", err)
+    }
+    data, err := gunzipIfNeeded(raw)
+    if err != nil || !isGzip(raw) {
+        t.Fatalf("Expected gzipped content, got %v", err)
+    }
+    for _, element := range []string{
+        "<loc>https://www.example.com/gallery</loc>",
+        "<image:loc>https://www.example.com/1.jpg</image:loc>",
+        `hreflang="fr" href="https://www.example.com/fr/gallery"`,
+    } {
+        if !strings.Contains(string(data), element) {
+            t.Fatalf("Expected %s in the sample, got:\n%s", element, data)
+        }
+    }
+    if sm.URLs[0].Loc != "/gallery" {
+        t.Fatalf("Expected URLs to be left untouched, got %s", sm.URLs[0].Loc)
+    }
+}
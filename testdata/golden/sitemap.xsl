@@ -0,0 +1,34 @@
+<?xml version="1.0" encoding="UTF-8"?>
+<xsl:stylesheet version="2.0"
+    xmlns:xsl="http://www.w3.org/1999/XSL/Transform"
+    xmlns:s="http://www.sitemaps.org/schemas/sitemap/0.9">
+    <xsl:output method="html" encoding="UTF-8" indent="yes"/>
+    <xsl:template match="/">
+        <html>
+        <head>
+            <title>Sitemap</title>
+            <style type="text/css">
+                body { font-family: Arial, sans-serif; }
+                table { border-collapse: collapse; width: 100%; }
+                th, td { text-align: left; padding: 8px; border-bottom: 1px solid #ddd; }
+                tr:hover {background-color: #f5f5f5;}
+            </style>
+        </head>
+        <body>
+            <h1>Sitemap</h1>
+            <table>
+                <tr>
+                    <th>URL</th>
+                    <th>Last Modified</th>
+                </tr>
+                <xsl:for-each select="//s:url | //s:sitemap">
+                    <tr>
+                        <td><a href="{s:loc}"><xsl:value-of select="s:loc"/></a></td>
+                        <td><xsl:value-of select="s:lastmod"/></td>
+                    </tr>
+                </xsl:for-each>
+            </table>
+        </body>
+        </html>
+    </xsl:template>
+</xsl:stylesheet>
@@ -0,0 +1,39 @@
+package nyxsitemap
+
+import (
+    "fmt"
+    "unicode/utf8"
+)
+
+// maxImageTextLength is the length, in characters, past which image titles
+// and captions are likely to be truncated by search engines.
+const maxImageTextLength = 2048
+
+// Warnings returns the problems noticed in the URLs written by the last
+// Write that don't make the sitemap invalid but are likely mistakes.
+func (s *SitemapOptions) Warnings() []string {
+    return s.warnings
+}
+
+// warn records a warning for the current run.
+func (s *SitemapOptions) warn(format string, args ...interface{}) {
+    s.warnings = append(s.warnings, fmt.Sprintf(format, args...))
+}
+
+// checkURL records warnings for a URL about to be written.
+func (s *SitemapOptions) checkURL(u SitemapURL) {
+    // Archived URLs are only as useful to crawlers as their lastmod. AddURL
+    // always fills it in, so this catches URLs set on URLs directly.
+    if u.ChangeFreq == "never" && u.LastMod == "" {
+        s.warn("'%s' has changefreq never but no lastmod", u.Loc)
+    }
+
+    for i, image := range u.Images {
+        if n := utf8.RuneCountInString(image.Title); n > maxImageTextLength {
+            s.warn("image %d of '%s' has a %d-character title, longer than %d", i+1, u.Loc, n, maxImageTextLength)
+        }
+        if n := utf8.RuneCountInString(image.Caption); n > maxImageTextLength {
+            s.warn("image %d of '%s' has a %d-character caption, longer than %d", i+1, u.Loc, n, maxImageTextLength)
+        }
+    }
+}
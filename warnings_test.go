@@ -0,0 +1,24 @@
+package nyxsitemap
+
+import (
+    "strings"
+    "testing"
+)
+
+func TestWarnChangeFreqNeverWithoutLastMod(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_warnings", "https://www.example.com")
+    sm.FS = NewMemFS()
+    sm.URLs = []SitemapURL{
+        {Loc: "/archive/2001", ChangeFreq: "never"},
+        {Loc: "/archive/2002", ChangeFreq: "never", LastMod: "2002-12-31"},
+        {Loc: "/", ChangeFreq: "daily"},
+    }
+
+    if err := sm.Write(""); err != nil {
+        t.Fatalf("Error writing sitemap: %v", err)
+    }
+    warnings := sm.Warnings()
+    if len(warnings) != 1 || !strings.Contains(warnings[0], "https://www.example.com/archive/2001") {
+        t.Fatalf("Expected a single warning for /archive/2001, got %v", warnings)
+    }
+}
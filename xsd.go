@@ -0,0 +1,101 @@
+//go:build cgo
+
+package nyxsitemap
+
+import (
+    "bytes"
+    "encoding/xml"
+    "fmt"
+    "net/url"
+    "strings"
+    "sync"
+
+    "github.com/lestrrat-go/libxml2"
+    "github.com/lestrrat-go/libxml2/xsd"
+)
+
+// schemaCache holds schemas combined from SchemaSources, keyed by the
+// source list. Entries are kept for the lifetime of the process.
+var (
+    schemaCacheMu sync.Mutex
+    schemaCache   = map[string]*xsd.Schema{}
+)
+
+// loadSchema returns the schema combining all the given sources, parsing
+// and caching it on first use. Sources may be file paths or http(s) URLs.
+func (s *SitemapOptions) loadSchema(sources []string) (*xsd.Schema, error) {
+    key := strings.Join(sources, "\n")
+
+    schemaCacheMu.Lock()
+    defer schemaCacheMu.Unlock()
+    if schema, ok := schemaCache[key]; ok {
+        return schema, nil
+    }
+
+    // Combine the sources through a wrapper schema importing each of them
+    wrapper := bytes.NewBufferString(xml.Header)
+    wrapper.WriteString(`<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">` + "\n")
+    for _, source := range sources {
+        filePath, namespace, err := s.readSchemaSource(source)
+        if err != nil {
+            return nil, err
+        }
+        location := (&url.URL{Scheme: "file", Path: filePath}).String()
+        wrapper.WriteString(`  <xs:import namespace="`)
+        xml.EscapeText(wrapper, []byte(namespace))
+        wrapper.WriteString(`" schemaLocation="`)
+        xml.EscapeText(wrapper, []byte(location))
+        wrapper.WriteString(`"/>` + "\n")
+    }
+    wrapper.WriteString("</xs:schema>\n")
+
+    schema, err := xsd.Parse(wrapper.Bytes())
+    if err != nil {
+        return nil, fmt.Errorf("failed to parse schema sources: %v", err)
+    }
+    schemaCache[key] = schema
+    return schema, nil
+}
+
+// validateXSD validates an XML document with libxml2 against the sitemap
+// XSD, or the sitemap index XSD if isIndex is true.
+func (s *SitemapOptions) validateXSD(data []byte, isIndex bool) error {
+    if onValidateXSD != nil {
+        onValidateXSD()
+    }
+
+    var err error
+    var schema *xsd.Schema
+    if !isIndex && len(s.SchemaSources) > 0 {
+        // Use the cached schema combined from the configured sources
+        schema, err = s.loadSchema(s.SchemaSources)
+        if err != nil {
+            return err
+        }
+    } else {
+        schemaData := sitemapXSD
+        if isIndex {
+            schemaData = sitemapIndexXSD
+        }
+
+        // Parse the schema
+        schema, err = xsd.Parse([]byte(schemaData))
+        if err != nil {
+            return fmt.Errorf("failed to parse schema: %v", err)
+        }
+        defer schema.Free()
+    }
+
+    // Parse the XML document
+    doc, err := libxml2.Parse(data)
+    if err != nil {
+        return fmt.Errorf("failed to parse XML: %v", err)
+    }
+    defer doc.Free()
+
+    // Validate the XML against the schema
+    if err := schema.Validate(doc); err != nil {
+        return fmt.Errorf("XML validation against schema failed: %v", err)
+    }
+    return nil
+}
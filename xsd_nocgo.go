@@ -0,0 +1,18 @@
+//go:build !cgo
+
+package nyxsitemap
+
+import "fmt"
+
+// validateXSD falls back to ValidateStructure without cgo, libxml2 being
+// unavailable. Extension elements aren't checked, and SchemaSources can't
+// be honored: they are rejected rather than silently ignored.
+func (s *SitemapOptions) validateXSD(data []byte, isIndex bool) error {
+    if onValidateXSD != nil {
+        onValidateXSD()
+    }
+    if !isIndex && len(s.SchemaSources) > 0 {
+        return fmt.Errorf("SchemaSources need libxml2, unavailable in builds without cgo; set Validator or SkipValidationFor instead")
+    }
+    return ValidateStructure(data, isIndex)
+}
@@ -0,0 +1,21 @@
+//go:build !cgo
+
+package nyxsitemap
+
+import (
+    "strings"
+    "testing"
+)
+
+// hasLibxml2 reports whether validation against XSD schemas is available.
+const hasLibxml2 = false
+
+func TestSchemaSourcesWithoutCgo(t *testing.T) {
+    sm := NewSitemapOptions("./test_sitemaps_schema_nocgo", "https://www.example.com")
+    sm.FS = NewMemFS()
+    sm.SchemaSources = []string{"testdata/sitemap.xsd"}
+    sm.AddURL(SitemapURL{Loc: "/", LastMod: "2023-10-25"})
+    if _, err := sm.WriteToMemory(""); err == nil || !strings.Contains(err.Error(), "cgo") {
+        t.Fatalf("Expected SchemaSources to be rejected without cgo, got: %v", err)
+    }
+}
@@ -0,0 +1,59 @@
+//go:build cgo
+
+package nyxsitemap
+
+import (
+    "os"
+    "path"
+    "strings"
+    "testing"
+)
+
+// hasLibxml2 reports whether validation against XSD schemas is available.
+const hasLibxml2 = true
+
+func TestSchemaSources(t *testing.T) {
+    dir := "./test_sitemaps_schema"
+    os.RemoveAll(dir)
+    os.MkdirAll(dir, 0755)
+    defer os.RemoveAll(dir)
+
+    sm := NewSitemapOptions(dir, "https://www.example.com")
+    sm.SchemaSources = []string{"testdata/sitemap.xsd", "testdata/sitemap-image.xsd"}
+
+    valid := `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9" xmlns:image="http://www.google.com/schemas/sitemap-image/1.1">
+  <url>
+    <loc>https://www.example.com/gallery</loc>
+    <lastmod>2023-10-25</lastmod>
+    <image:image>
+      <image:loc>https://www.example.com/images/1.jpg</image:loc>
+      <image:title>First image</image:title>
+    </image:image>
+  </url>
+</urlset>
+`
+    validFile := path.Join(dir, "valid.xml")
+    os.WriteFile(validFile, []byte(valid), 0644)
+    if err := sm.validateXMLFile(validFile, false); err != nil {
+        t.Fatalf("Expected image sitemap to validate, got: %v", err)
+    }
+
+    // The image:loc child is required by the image schema
+    invalid := strings.Replace(valid, "<image:loc>https://www.example.com/images/1.jpg</image:loc>", "", 1)
+    invalidFile := path.Join(dir, "invalid.xml")
+    os.WriteFile(invalidFile, []byte(invalid), 0644)
+    if err := sm.validateXMLFile(invalidFile, false); err == nil {
+        t.Fatalf("Expected image without loc to fail validation")
+    }
+
+    // The combined schema is parsed once and reused
+    first, err := sm.loadSchema(sm.SchemaSources)
+    if err != nil {
+        t.Fatalf("Error loading schema: %v", err)
+    }
+    second, _ := sm.loadSchema(sm.SchemaSources)
+    if first != second {
+        t.Fatalf("Expected combined schema to be cached")
+    }
+}